@@ -2,14 +2,20 @@ package main
 
 import (
 //	"flag"
+	"context"
+	"encoding/json"
 	"os"
 	"fmt"
 	"time"
 	"strings"
+	"strconv"
+	"sort"
+	"text/tabwriter"
 //	genericapiserver "k8s.io/apiserver/pkg/server"
 //	"github.com/cloud-ark/kubediscovery/pkg/cmd/server"
 	"github.com/cloud-ark/kubediscovery/pkg/discovery"
 	"github.com/cloud-ark/kubediscovery/pkg/apiserver"
+	"k8s.io/client-go/rest"
 	"runtime/pprof"
 	"flag"
 	"log"
@@ -43,32 +49,26 @@ func main() {
 			for k, _ := range discovery.ALLOWED_COMMANDS {
 				fmt.Printf("%s ", k)
 			}
+			fmt.Printf("\n")
+			os.Exit(2)
 		}
 		if commandType == "composition" {
 			if len(os.Args) < 5 {
 				panic("Not enough arguments: ./kubediscovery composition <kind> <instance> <namespace>")
 			}
-			if len(os.Args) == 5 {
-				kind = os.Args[2]
-				instance = os.Args[3]
-				namespace = os.Args[4]
-				discovery.BuildConfig("")
-			}
-			if len(os.Args) == 6 {
-				kind = os.Args[2]
-				instance = os.Args[3]
-				namespace = os.Args[4]
-				kubeconfig := os.Args[5]
-				kubeconfigparts := strings.Split(kubeconfig, "=")
-				kubeconfigpath := kubeconfigparts[1]
-				//fmt.Printf("Kubeconfig Path:%s\n", kubeconfigpath)
-				discovery.BuildConfig(kubeconfigpath)
+			kind = os.Args[2]
+			instance = os.Args[3]
+			namespace = os.Args[4]
+			kubeconfigpath := getOption("--kubeconfig")
+			//fmt.Printf("Kubeconfig Path:%s\n", kubeconfigpath)
+			d := buildDiscoverer(kubeconfigpath, discovery.Options{Namespaces: namespacesOption()})
+			ref := discovery.ObjectRef{Kind: kind, Name: instance, Namespace: namespace}
+			compositions, err := d.Composition(context.Background(), ref)
+			if err != nil {
+				exitOnError(err)
 			}
-			discovery.BuildCompositionTree(namespace)
-			composition := discovery.TotalClusterCompositions.GetCompositionsString(kind,
-																			  instance,
-																			  namespace)
-			fmt.Printf("%s\n", composition)
+			composition, _ := json.Marshal(compositions)
+			fmt.Printf("%s\n", string(composition))
 		}
 		if commandType == "connections" {
 			if len(os.Args) < 5  {
@@ -78,10 +78,7 @@ func main() {
 			kind = os.Args[2]
 			instance = os.Args[3]
 			namespace = os.Args[4]
-			discovery.OriginalInputNamespace = namespace
-			discovery.OriginalInputKind = kind
-			discovery.OriginalInputInstance = instance
-			discovery.OutputFormat = "default"
+			outputFormat := "default"
 
 			/*
 			if len(os.Args) == 7 {
@@ -99,76 +96,28 @@ func main() {
 				discovery.BuildConfig("")
 			}*/
 
-			discovery.RelsToIgnore = ""
-			kubeconfigpath := ""
-			for _, opt := range os.Args {
-				//fmt.Printf("Opt:%s\n", opt)
-				parts := strings.Split(opt, "=")
-				if len(parts) == 2 {
-					option := parts[0]
-					optVal := parts[1]
-					ignorefound := strings.EqualFold(option, "--ignore")
-					if ignorefound {
-						discovery.RelsToIgnore = optVal
-					}
-					opformatfound := strings.EqualFold(option, "--output")
-					if opformatfound {
-					    //parts = strings.Split(opt, "=")
-						discovery.OutputFormat = optVal
-					}
-					kubeconfigfound := strings.EqualFold(option, "--kubeconfig")
-					if kubeconfigfound {
-						kubeconfigpath = optVal
-						/*kubeconfig := opt
-						kubeconfigparts := strings.Split(kubeconfig, "=")
-						kubeconfigpath = kubeconfigparts[1]
-						//fmt.Printf("Kubeconfig Path:%s\n", kubeconfigpath)*/			
-					}
-				}
+			relsToIgnore := getOption("--ignore")
+			kubeconfigpath := getOption("--kubeconfig")
+			if getOption("--output") != "" {
+				outputFormat = getOption("--output")
 			}
-			//fmt.Printf("O/P format:%s\n", discovery.OutputFormat)
+			//fmt.Printf("O/P format:%s\n", outputFormat)
 			//fmt.Printf("Kubeconfig path:%s\n", kubeconfigpath)
-			//fmt.Printf("IgnoreList:%s\n", discovery.RelsToIgnore)
-			discovery.BuildConfig(kubeconfigpath)
-
-			_ = discovery.ReadKinds(kind)
-			exists := discovery.CheckExistence(kind, instance, namespace)
-			if exists {
-				// Prefetching does not seem to improve performance.
-				// In fact, it degrades performance by few milliseconds.
-				// So turning pre-fetching off
-				//discovery.FetchGVKs(namespace)
-				level := 0
-				visited := make([]discovery.Connection, 0)
-				relationType := ""
-				discovery.OrigKind = kind
-				discovery.OrigName = instance
-				discovery.OrigNamespace = namespace
-				discovery.OrigLevel = level
-				// Build the composition tree
-				discovery.BuildCompositionTree(namespace)
-				root := discovery.Connection{
-					Name: instance,
-					Kind: kind,
-					Namespace: namespace,
-					Level: level,
-					Peer: &discovery.Connection{
-						Name: "",
-						Kind: "",
-						Namespace: "",
-					},
-				}
-				discovery.TotalClusterConnections = discovery.AppendConnections(discovery.TotalClusterConnections, root)
+			//fmt.Printf("IgnoreList:%s\n", relsToIgnore)
+			options := discovery.Options{Ignore: relsToIgnore, Namespaces: namespacesOption(),
+				InferReferences: hasFlag("--infer-refs")}
+			if outputFormat != "json" {
+				options.Progress = os.Stdout
+			}
+			d := buildDiscoverer(kubeconfigpath, options)
 
-				level = level + 1
-				visited = discovery.GetRelatives(visited, level, kind, instance, discovery.OrigKind, discovery.OrigName, 
-													 namespace, relationType)
-				if len(discovery.TotalClusterConnections) > 0 {
-					discovery.PrintRelatives(discovery.OutputFormat, discovery.TotalClusterConnections)
-				}
-			} else {
-				fmt.Printf("Resource %s of kind %s in namespace %s does not exist.\n", instance, kind, namespace)
-				os.Exit(1)
+			ref := discovery.ObjectRef{Kind: kind, Name: instance, Namespace: namespace}
+			connections, err := d.Connections(context.Background(), ref)
+			if err != nil {
+				exitOnError(err)
+			}
+			if len(connections) > 0 {
+				discovery.PrintRelatives(outputFormat, connections)
 			}
 		}
 		if commandType == "man" {
@@ -177,30 +126,217 @@ func main() {
 				panic("Not enough arguments:./kubediscovery man <kind> --kubeconfig=<Full path to kubeconfig>")
 			}*/
 
+			// kubediscovery man <kind> [<namespace>] [--snapshot=snap.tar.gz]
+			if len(os.Args) < 3 {
+				exitOnError(fmt.Errorf("Not enough arguments: ./kubediscovery man <kind> [<namespace>]"))
+			}
 			kind := os.Args[2]
-			namespace := os.Args[3]
-			kubeconfig1 := os.Args[4]
-			parts := strings.Split(kubeconfig1, "=")
-
-			if len(parts) == 2 && parts[1] != "" {
-				trimmedKubeconfig := strings.TrimSpace(parts[1])
-				discovery.BuildConfig(trimmedKubeconfig)
-			} else {
-				discovery.BuildConfig("")
+			namespace := "default"
+			if len(os.Args) > 3 && !strings.HasPrefix(os.Args[3], "-") {
+				namespace = os.Args[3]
 			}
+			d := buildDiscoverer(getOption("--kubeconfig"), discovery.Options{})
 
-			manPage := apiserver.GetManPage(kind, namespace)
+			manPage, err := apiserver.GetManPage(context.Background(), d, kind, namespace)
+			if err != nil {
+				exitOnError(err)
+			}
 			fmt.Printf("%s\n", manPage)
 		}
+		if commandType == "snapshot" {
+			// kubediscovery snapshot --namespace ns --out snap.tar.gz
+			namespace := getOption("--namespace")
+			out := getOption("--out")
+			if out == "" {
+				out = "kubediscovery-snapshot.tar.gz"
+			}
+			d := buildDiscoverer(getOption("--kubeconfig"), discovery.Options{})
+			manifest, err := d.WriteSnapshot(context.Background(), namespace, out)
+			if err != nil {
+				exitOnError(fmt.Errorf("writing snapshot: %w", err))
+			}
+			kinds := make([]string, 0)
+			for kind := range manifest.Skipped {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			for _, kind := range kinds {
+				fmt.Printf("Skipping %s: %s\n", kind, manifest.Skipped[kind])
+			}
+			total := 0
+			for _, count := range manifest.Kinds {
+				total = total + count
+			}
+			fmt.Printf("Wrote %d objects of %d kinds to %s\n", total, len(manifest.Kinds), out)
+		}
+		if commandType == "validate-crd" {
+			// kubediscovery validate-crd [<file or directory>|<CRD name or kind>]
+			name := ""
+			if len(os.Args) > 2 && !strings.HasPrefix(os.Args[2], "-") {
+				name = os.Args[2]
+			}
+			var d *discovery.Discoverer
+			if info, err := os.Stat(name); name != "" && err == nil {
+				// Check the CRDs in the file instead of the cluster
+				var manifests *discovery.Manifests
+				if info.IsDir() {
+					manifests, err = discovery.LoadManifests(name, "")
+				} else {
+					manifests, err = discovery.LoadManifests("", name)
+				}
+				if err != nil {
+					exitOnError(fmt.Errorf("loading manifests: %w", err))
+				}
+				d, err = discovery.NewDiscoverer(nil, discovery.Options{Manifests: manifests})
+				if err != nil {
+					exitOnError(err)
+				}
+				name = ""
+			} else {
+				d = buildDiscoverer(getOption("--kubeconfig"), discovery.Options{})
+			}
+			reports, err := d.ValidateCRDs(context.Background(), name)
+			if err != nil {
+				exitOnError(err)
+			}
+			if printCRDReports(reports) > 0 {
+				os.Exit(1)
+			}
+		}
+		if commandType == "kinds" {
+			// kubediscovery kinds [<kind>] [--rules=<file>,...] [--packs=<pack>,...] [--output=json]
+			name := ""
+			if len(os.Args) > 2 && !strings.HasPrefix(os.Args[2], "-") {
+				name = os.Args[2]
+			}
+			d := buildDiscoverer(getOption("--kubeconfig"), discovery.Options{})
+			kinds, err := d.Kinds(context.Background(), name)
+			if err != nil {
+				exitOnError(err)
+			}
+			if getOption("--output") == "json" {
+				kindsJSON, _ := json.Marshal(kinds)
+				fmt.Printf("%s\n", string(kindsJSON))
+			} else {
+				printKinds(kinds)
+			}
+		}
+		if commandType == "env" {
+			// kubediscovery env <pod> [<namespace>] [--output=json]
+			if len(os.Args) < 3 {
+				exitOnError(fmt.Errorf("Not enough arguments: ./kubediscovery env <pod> [<namespace>]"))
+			}
+			instance = os.Args[2]
+			namespace = "default"
+			if len(os.Args) > 3 && !strings.HasPrefix(os.Args[3], "-") {
+				namespace = os.Args[3]
+			}
+			d := buildDiscoverer(getOption("--kubeconfig"), discovery.Options{})
+			ref := discovery.ObjectRef{Kind: "Pod", Name: instance, Namespace: namespace}
+			envVars, err := d.Env(context.Background(), ref)
+			if err != nil {
+				exitOnError(err)
+			}
+			if getOption("--output") == "json" {
+				envJSON, _ := json.Marshal(envVars)
+				fmt.Printf("%s\n", string(envJSON))
+			} else {
+				printEnv(envVars)
+			}
+		}
+		if commandType == "permissions" {
+			// kubediscovery permissions <kind> <name> [<namespace>] [--output=json]
+			// kind can also be User or Group
+			if len(os.Args) < 4 {
+				exitOnError(fmt.Errorf("Not enough arguments: ./kubediscovery permissions <kind> <name> [<namespace>]"))
+			}
+			kind = os.Args[2]
+			instance = os.Args[3]
+			namespace = "default"
+			if len(os.Args) > 4 && !strings.HasPrefix(os.Args[4], "-") {
+				namespace = os.Args[4]
+			}
+			d := buildDiscoverer(getOption("--kubeconfig"), discovery.Options{Namespaces: namespacesOption()})
+			ref := discovery.ObjectRef{Kind: kind, Name: instance, Namespace: namespace}
+			permissions, err := d.Permissions(context.Background(), ref)
+			if err != nil {
+				exitOnError(err)
+			}
+			if getOption("--output") == "json" {
+				permissionsJSON, _ := json.Marshal(permissions)
+				fmt.Printf("%s\n", string(permissionsJSON))
+			} else {
+				printPermissions(permissions)
+			}
+		}
+		if commandType == "storage" {
+			// kubediscovery storage <kind> <name> [<namespace>] [--output=json]
+			if len(os.Args) < 4 {
+				exitOnError(fmt.Errorf("Not enough arguments: ./kubediscovery storage <kind> <name> [<namespace>]"))
+			}
+			kind = os.Args[2]
+			instance = os.Args[3]
+			namespace = "default"
+			if len(os.Args) > 4 && !strings.HasPrefix(os.Args[4], "-") {
+				namespace = os.Args[4]
+			}
+			d := buildDiscoverer(getOption("--kubeconfig"), discovery.Options{})
+			ref := discovery.ObjectRef{Kind: kind, Name: instance, Namespace: namespace}
+			storage, err := d.Storage(context.Background(), ref)
+			if err != nil {
+				exitOnError(err)
+			}
+			if getOption("--output") == "json" {
+				storageJSON, _ := json.Marshal(storage)
+				fmt.Printf("%s\n", string(storageJSON))
+			} else {
+				printStorage(storage, "")
+			}
+		}
+		if commandType == "reachability" {
+			// kubediscovery reachability <[namespace/]pod> <[namespace/]pod> [<namespace>] [--port=80] [--protocol=TCP]
+			if len(os.Args) < 4 {
+				exitOnError(fmt.Errorf("Not enough arguments: ./kubediscovery reachability <pod> <pod> [<namespace>] [--port=<port>]"))
+			}
+			namespace = "default"
+			if len(os.Args) > 4 && !strings.HasPrefix(os.Args[4], "-") {
+				namespace = os.Args[4]
+			}
+			from := podRef(os.Args[2], namespace)
+			to := podRef(os.Args[3], namespace)
+			port := 0
+			if getOption("--port") != "" {
+				var err error
+				port, err = strconv.Atoi(getOption("--port"))
+				if err != nil {
+					exitOnError(fmt.Errorf("invalid port %s", getOption("--port")))
+				}
+			}
+			protocol := strings.ToUpper(getOption("--protocol"))
+			d := buildDiscoverer(getOption("--kubeconfig"), discovery.Options{})
+			reachability, err := d.Reachability(context.Background(), from, to, port, protocol)
+			if err != nil {
+				exitOnError(err)
+			}
+			if getOption("--output") == "json" {
+				reachabilityJSON, _ := json.Marshal(reachability)
+				fmt.Printf("%s\n", string(reachabilityJSON))
+			} else {
+				printReachability(from, to, port, protocol, reachability)
+			}
+		}
 		if commandType == "networkmetrics" {
 
                         nodeName := os.Args[2]
                         kubeconfig1 := os.Args[3]
                         parts := strings.Split(kubeconfig1, "=")
                         trimmedKubeconfig := strings.TrimSpace(parts[1])
-                        discovery.BuildConfig(trimmedKubeconfig)
+                        d := buildDiscoverer(trimmedKubeconfig, discovery.Options{})
 
-                        cAdvisorMetrics := discovery.GetCAdvisorMetrics(nodeName)
+                        cAdvisorMetrics, err := d.GetCAdvisorMetrics(context.Background(), nodeName)
+                        if err != nil {
+                                exitOnError(err)
+                        }
                         fmt.Printf(cAdvisorMetrics)
 		}
 		if commandType == "podmetrics" {
@@ -209,9 +345,12 @@ func main() {
                         kubeconfig1 := os.Args[3]
                         parts := strings.Split(kubeconfig1, "=")
                         trimmedKubeconfig := strings.TrimSpace(parts[1])
-                        discovery.BuildConfig(trimmedKubeconfig)
+                        d := buildDiscoverer(trimmedKubeconfig, discovery.Options{})
 
-                        podMetrics := discovery.GetKubeletMetrics(nodeName)
+                        podMetrics, err := d.GetKubeletMetrics(context.Background(), nodeName)
+                        if err != nil {
+                                exitOnError(err)
+                        }
                         //fmt.Printf("-----\n")
                         fmt.Printf(podMetrics)
 
@@ -219,8 +358,8 @@ func main() {
 	} else {
 		fmt.Printf("Running from within cluster.\n")
 		fmt.Printf("Installing KubePlus paths.\n")
-		discovery.BuildConfig("")
-		go apiserver.InstallKubePlusPaths()
+		d := buildDiscoverer("", discovery.Options{})
+		go apiserver.InstallKubePlusPaths(d)
 		fmt.Printf("After installing KubePlus paths.\n")
 		// Run forever
 		for {
@@ -235,3 +374,278 @@ func main() {
 		}*/
 	}
 }
+
+// Options are given as --name=value or --name value anywhere after the
+// command. An option without a value is an error.
+func getOption(name string) string {
+	for i, opt := range os.Args {
+		parts := strings.SplitN(opt, "=", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], name) {
+			return strings.TrimSpace(parts[1])
+		}
+		if len(parts) == 1 && strings.EqualFold(opt, name) {
+			// "-" is stdin for --from-file
+			if i+1 == len(os.Args) || (strings.HasPrefix(os.Args[i+1], "-") && os.Args[i+1] != "-") {
+				exitOnError(fmt.Errorf("%s needs a value", name))
+			}
+			return strings.TrimSpace(os.Args[i+1])
+		}
+	}
+	return ""
+}
+
+// Flags are given as --name anywhere after the command.
+func hasFlag(name string) bool {
+	for _, opt := range os.Args {
+		if strings.EqualFold(opt, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// -A/--all-namespaces, or --namespaces=a,b,c
+func namespacesOption() []string {
+	for _, opt := range os.Args {
+		if opt == "-A" || opt == "--all-namespaces" {
+			return []string{""}
+		}
+	}
+	namespaces := make([]string, 0)
+	for _, ns := range strings.Split(getOption("--namespaces"), ",") {
+		if strings.TrimSpace(ns) != "" {
+			namespaces = append(namespaces, strings.TrimSpace(ns))
+		}
+	}
+	return namespaces
+}
+
+// Reads objects from a --snapshot archive or --from-dir/--from-file manifests
+// when given, otherwise from the cluster.
+func buildDiscoverer(kubeconfigpath string, options discovery.Options) *discovery.Discoverer {
+	var cfg *rest.Config
+	snapshot := getOption("--snapshot")
+	fromDir := getOption("--from-dir")
+	fromFile := getOption("--from-file")
+	if snapshot != "" {
+		manifests, _, err := discovery.LoadSnapshot(snapshot)
+		if err != nil {
+			exitOnError(fmt.Errorf("loading snapshot: %w", err))
+		}
+		options.Manifests = manifests
+	} else if fromDir != "" || fromFile != "" {
+		manifests, err := discovery.LoadManifests(fromDir, fromFile)
+		if err != nil {
+			exitOnError(fmt.Errorf("loading manifests: %w", err))
+		}
+		options.Manifests = manifests
+	} else {
+		var err error
+		cfg, err = discovery.BuildConfig(kubeconfigpath)
+		if err != nil {
+			exitOnError(err)
+		}
+	}
+	// --rules=a.yaml,b.yaml
+	for _, path := range strings.Split(getOption("--rules"), ",") {
+		if strings.TrimSpace(path) != "" {
+			options.RuleFiles = append(options.RuleFiles, strings.TrimSpace(path))
+		}
+	}
+	// --packs=cert-manager,istio or --packs=none
+	for _, pack := range strings.Split(getOption("--packs"), ",") {
+		if strings.TrimSpace(pack) != "" {
+			options.Packs = append(options.Packs, strings.TrimSpace(pack))
+		}
+	}
+	d, err := discovery.NewDiscoverer(cfg, options)
+	if err != nil {
+		exitOnError(err)
+	}
+	return d
+}
+
+// Prints the problems found in the annotations of each CRD, and the CRDs that
+// declare no kubediscovery annotations. Returns the number of problems.
+func printCRDReports(reports []discovery.CRDReport) int {
+	problems := 0
+	undeclared := make([]string, 0)
+	for _, report := range reports {
+		if len(report.Annotations) == 0 {
+			undeclared = append(undeclared, report.Name)
+			continue
+		}
+		fmt.Printf("%s (%s)\n", report.Name, report.Kind)
+		for _, annotation := range report.Annotations {
+			ok := true
+			for _, problem := range report.Problems {
+				if problem.Annotation != annotation {
+					continue
+				}
+				ok = false
+				problems = problems + 1
+				if problem.Pos < 0 {
+					fmt.Printf("  %s: %s\n", annotation, problem.Message)
+				} else {
+					fmt.Printf("  %s: column %d: %s\n", annotation, problem.Pos+1, problem.Message)
+					fmt.Printf("    %s\n", problem.Value)
+					fmt.Printf("    %s^\n", strings.Repeat(" ", problem.Pos))
+				}
+			}
+			if ok {
+				fmt.Printf("  %s: ok\n", annotation)
+			}
+		}
+	}
+	fmt.Printf("\n%d of %d CRDs declare kubediscovery annotations, %d problems found.\n", len(reports)-len(undeclared), len(reports), problems)
+	if len(undeclared) > 0 {
+		fmt.Printf("CRDs without kubediscovery annotations:\n")
+		for _, name := range undeclared {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+	return problems
+}
+
+// Prints the variables of each container with the object and key they come from.
+func printEnv(envVars []discovery.EnvVar) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	container := ""
+	for _, envVar := range envVars {
+		if envVar.ContainerType+"/"+envVar.Container != container {
+			container = envVar.ContainerType + "/" + envVar.Container
+			fmt.Fprintf(w, "%s (%s)\n", envVar.Container, envVar.ContainerType)
+		}
+		from := envVar.Source
+		switch envVar.Source {
+		case "ConfigMap", "Secret":
+			from = from + " " + envVar.SourceName
+			if envVar.Key != "" {
+				from = from + " key " + envVar.Key
+			}
+		case "fieldRef":
+			from = from + " " + envVar.Key
+		case "resourceFieldRef":
+			from = from + " " + envVar.SourceName + " " + envVar.Key
+		}
+		value := envVar.Value
+		if envVar.Source == "Secret" {
+			value = "(secret)"
+		}
+		if envVar.Missing {
+			value = "(missing)"
+			if envVar.Optional {
+				value = "(missing, optional)"
+			}
+		}
+		name := envVar.Name
+		if name == "" {
+			name = "*"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", name, value, from, envVar.Path)
+	}
+	w.Flush()
+}
+
+// Prints one line per resource, or non-resource URL, with the verbs allowed
+// on it and the bindings that allow them.
+func printPermissions(permissions []discovery.Permission) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "NAMESPACE\tRESOURCE\tRESOURCE NAMES\tVERBS\tVIA\n")
+	for _, permission := range permissions {
+		namespace := permission.Namespace
+		if namespace == "" {
+			namespace = "*"
+		}
+		resource := permission.NonResourceURL
+		if resource == "" {
+			resource = permission.Resource
+			if permission.APIGroup != "" {
+				resource = resource + "." + permission.APIGroup
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t[%s]\t[%s]\t%s\n", namespace, resource, strings.Join(permission.ResourceNames, " "),
+			strings.Join(permission.Verbs, " "), strings.Join(permission.Via, ", "))
+	}
+	w.Flush()
+}
+
+// Unless a single kind was asked for, kinds without a composition or
+// relationships are left out
+func printKinds(kinds []discovery.KindInfo) {
+	for _, info := range kinds {
+		if len(kinds) > 1 && len(info.Composition) == 0 && len(info.Relationships) == 0 {
+			continue
+		}
+		fmt.Printf("%s\n", info.Kind)
+		for _, rule := range info.Composition {
+			fmt.Printf("  composition: %s [%s]\n", rule.Value, rule.Source)
+		}
+		for _, rule := range info.Relationships {
+			if rule.Error != "" {
+				fmt.Printf("  %s [%s] error: %s\n", rule.Value, rule.Source, rule.Error)
+			} else {
+				fmt.Printf("  %s [%s]\n", rule.Value, rule.Source)
+			}
+		}
+	}
+}
+
+// Prints the lineage as a tree, one object per line with its attributes
+func printStorage(node discovery.StorageNode, indent string) {
+	name := node.Name
+	if node.Namespace != "" {
+		name = node.Namespace + "/" + node.Name
+	}
+	attributes := make([]string, 0)
+	for key, value := range node.Attributes {
+		attributes = append(attributes, key+":"+value)
+	}
+	sort.Strings(attributes)
+	if len(attributes) > 0 {
+		name = name + " (" + strings.Join(attributes, " ") + ")"
+	}
+	fmt.Printf("%s%s %s\n", indent, node.Kind, name)
+	for _, child := range node.Children {
+		printStorage(child, indent+"  ")
+	}
+}
+
+// Pods can be given as namespace/name
+func podRef(name, namespace string) discovery.ObjectRef {
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 {
+		return discovery.ObjectRef{Kind: "Pod", Name: parts[1], Namespace: parts[0]}
+	}
+	return discovery.ObjectRef{Kind: "Pod", Name: name, Namespace: namespace}
+}
+
+func printReachability(from, to discovery.ObjectRef, port int, protocol string, reachability discovery.Reachability) {
+	if protocol == "" {
+		protocol = "TCP"
+	}
+	traffic := protocol + " (any port)"
+	if port != 0 {
+		traffic = fmt.Sprintf("%s/%d", protocol, port)
+	}
+	verdict := "denied"
+	if reachability.Allowed {
+		verdict = "allowed"
+	}
+	fmt.Printf("Traffic from Pod %s/%s to Pod %s/%s on %s: %s\n", from.Namespace, from.Name, to.Namespace, to.Name, traffic, verdict)
+	for _, side := range []struct {
+		name string
+		decision discovery.PolicyDecision
+	}{{"egress", reachability.Egress}, {"ingress", reachability.Ingress}} {
+		verdict = "denied"
+		if side.decision.Allowed {
+			verdict = "allowed"
+		}
+		fmt.Printf("  %s: %s, %s\n", side.name, verdict, side.decision.Reason)
+	}
+}
+
+func exitOnError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+	os.Exit(1)
+}
@@ -1,6 +1,8 @@
 package apiserver
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 
@@ -129,7 +131,12 @@ func (c completedConfig) New() (*DiscoveryServer, error) {
 	return s, nil
 }
 
-func handleResourceDetailsEndpoint(request *restful.Request, response *restful.Response) {
+// Serves the KubePlus endpoints from a single Discoverer.
+type kubePlusHandler struct {
+	d *discovery.Discoverer
+}
+
+func (h *kubePlusHandler) handleResourceDetailsEndpoint(request *restful.Request, response *restful.Response) {
 
 	resourceKind := request.QueryParameter(KIND_QUERY_PARAM)
 	resourceInstance := request.QueryParameter(INSTANCE_QUERY_PARAM)
@@ -139,29 +146,35 @@ func handleResourceDetailsEndpoint(request *restful.Request, response *restful.R
 	if namespace == "" {
 		namespace = "default"
 	}
-	resourceInfo := discovery.TotalClusterCompositions.QueryResource(resourceKind, resourceInstance, namespace)
-	fmt.Printf("Resource Info:%v\n", resourceInfo)
+	ref := discovery.ObjectRef{Kind: resourceKind, Name: resourceInstance, Namespace: namespace}
+	resourceInfo, err := h.d.QueryResource(request.Request.Context(), ref)
+	if err != nil {
+		writeError(response, err)
+		return
+	}
+	fmt.Printf("Resource Info:%s\n", string(resourceInfo))
 
-	response.Write([]byte(resourceInfo))
+	response.Write(resourceInfo)
 }
 
-func InstallKubePlusPaths() {//discoveryServer *DiscoveryServer) {
+func InstallKubePlusPaths(d *discovery.Discoverer) {//discoveryServer *DiscoveryServer) {
 
 	fmt.Printf("Inside InstallKubePlusPaths...")
 	path := "/apis/" + GroupName + "/" + GroupVersion
 
+	h := &kubePlusHandler{d: d}
 	ws1 := getWebService()
 	ws1.Path(path).
 		Consumes(restful.MIME_JSON, restful.MIME_XML).
 		Produces(restful.MIME_JSON, restful.MIME_XML)
 
 	ws1.Route(ws1.GET("/helloworld").To(handleHelloWorld))
-	ws1.Route(ws1.GET("/explain").To(handleExplainEndpoint))
-	ws1.Route(ws1.GET("/composition").To(handleCompositionEndpoint))
-	//ws1.Route(ws1.GET("/implementation_details").To(handleImplementationDetailsEndpoint))
-	//ws1.Route(ws1.GET("/usage").To(handleUsageEndpoint))
-	ws1.Route(ws1.GET("/man").To(handleManPageEndpoint))
-	ws1.Route(ws1.GET("/resourceDetails").To(handleResourceDetailsEndpoint))
+	ws1.Route(ws1.GET("/explain").To(h.handleExplainEndpoint))
+	ws1.Route(ws1.GET("/composition").To(h.handleCompositionEndpoint))
+	//ws1.Route(ws1.GET("/implementation_details").To(h.handleImplementationDetailsEndpoint))
+	//ws1.Route(ws1.GET("/usage").To(h.handleUsageEndpoint))
+	ws1.Route(ws1.GET("/man").To(h.handleManPageEndpoint))
+	ws1.Route(ws1.GET("/resourceDetails").To(h.handleResourceDetailsEndpoint))
 	restful.Add(ws1)
 	http.ListenAndServe(":8080", nil)
 	fmt.Printf("Done installing KubePlus paths...")
@@ -174,33 +187,39 @@ func handleHelloWorld(request *restful.Request, response *restful.Response) {
 	response.Write([]byte(queryResponse))
 }
 
-func handleExplainEndpoint(request *restful.Request, response *restful.Response) {
+func (h *kubePlusHandler) handleExplainEndpoint(request *restful.Request, response *restful.Response) {
 	customResourceKind := request.QueryParameter(KIND_QUERY_PARAM)
 	customResourceKind, queryKind := getQueryKind(customResourceKind)
-	openAPISpec, err := discovery.GetOpenAPISpec(customResourceKind)
-	queryResponse := ""
+	openAPISpec, err := h.d.GetOpenAPISpec(request.Request.Context(), customResourceKind)
 	if err != nil {
-		queryResponse = "Error in retrieving OpenAPI Spec for Custom Resource:"
-	} else if openAPISpec != "" {
+		writeError(response, err)
+		return
+	}
+	queryResponse := ""
+	if openAPISpec != "" {
 		queryResponse = parseOpenAPISpec([]byte(openAPISpec), queryKind)
 	}
 
 	response.Write([]byte(queryResponse))
 }
 
-func handleManPageEndpoint(request *restful.Request, response *restful.Response) {
+func (h *kubePlusHandler) handleManPageEndpoint(request *restful.Request, response *restful.Response) {
 	customResourceKind := request.QueryParameter(KIND_QUERY_PARAM)
 
 	namespace := "default"
-	manPage := GetManPage(customResourceKind, namespace)
+	manPage, err := GetManPage(request.Request.Context(), h.d, customResourceKind, namespace)
+	if err != nil {
+		writeError(response, err)
+		return
+	}
 
 	response.Write([]byte(manPage))
 }
 
-func GetManPage(customResourceKind string, namespace string) string {
+func GetManPage(ctx context.Context, d *discovery.Discoverer, customResourceKind string, namespace string) (string, error) {
 	//fmt.Printf("Custom Resource Kind:%s\n", customResourceKind)
 
-	/*implementationDetails, err := discovery.GetImplementationDetails(customResourceKind)
+	/*implementationDetails, err := d.GetImplementationDetails(ctx, customResourceKind)
 
 	if err != nil {
 		implementationDetails = "Error in retrieving implementation details for Custom Resource:"
@@ -209,40 +228,45 @@ func GetManPage(customResourceKind string, namespace string) string {
 	fmt.Println("Implementation choices:%v", implementationDetails)
 	*/
 
-	manPage := discovery.GetUsageDetails(customResourceKind, namespace)
+	manPage, err := d.GetUsageDetails(ctx, customResourceKind, namespace)
 	//fmt.Println("Usage guidelines:%v", manPage)
 
-	return manPage
+	return manPage, err
 }
 
-func handleImplementationDetailsEndpoint(request *restful.Request, response *restful.Response) {
+func (h *kubePlusHandler) handleImplementationDetailsEndpoint(request *restful.Request, response *restful.Response) {
 	customResourceKind := request.QueryParameter(KIND_QUERY_PARAM)
 	fmt.Printf("Custom Resource Kind:%s\n", customResourceKind)
 
-	implementationDetails, err := discovery.GetImplementationDetails(customResourceKind)
+	implementationDetails, err := h.d.GetImplementationDetails(request.Request.Context(), customResourceKind)
 
 	if err != nil {
-		implementationDetails = "Error in retrieving implementation details for Custom Resource:"
+		writeError(response, err)
+		return
 	}
 
-	fmt.Println("Implementation details:%v", implementationDetails)
+	fmt.Printf("Implementation details:%v\n", implementationDetails)
 
 	response.Write([]byte(implementationDetails))
 }
 
-func handleUsageEndpoint(request *restful.Request, response *restful.Response) {
+func (h *kubePlusHandler) handleUsageEndpoint(request *restful.Request, response *restful.Response) {
 	customResourceKind := request.QueryParameter(KIND_QUERY_PARAM)
 	fmt.Printf("Custom Resource Kind:%s\n", customResourceKind)
 
 	namespace := "default"
-	usageDetails := discovery.GetUsageDetails(customResourceKind, namespace)
+	usageDetails, err := h.d.GetUsageDetails(request.Request.Context(), customResourceKind, namespace)
+	if err != nil {
+		writeError(response, err)
+		return
+	}
 
-	fmt.Println("Usage details:%v", usageDetails)
+	fmt.Printf("Usage details:%v\n", usageDetails)
 
 	response.Write([]byte(usageDetails))
 }
 
-func handleCompositionEndpoint(request *restful.Request, response *restful.Response) {
+func (h *kubePlusHandler) handleCompositionEndpoint(request *restful.Request, response *restful.Response) {
 	resourceKind := request.QueryParameter(KIND_QUERY_PARAM)
 	resourceInstance := request.QueryParameter(INSTANCE_QUERY_PARAM)
 	namespace := request.QueryParameter(NAMESPACE_QUERY_PARAM)
@@ -251,14 +275,40 @@ func handleCompositionEndpoint(request *restful.Request, response *restful.Respo
 		namespace = "default"
 	}
 
-	discovery.BuildCompositionTree(namespace)
+	ref := discovery.ObjectRef{Kind: resourceKind, Name: resourceInstance, Namespace: namespace}
+	compositions, err := h.d.Composition(request.Request.Context(), ref)
+	if err != nil {
+		writeError(response, err)
+		return
+	}
+	compositionInfo, err := json.Marshal(compositions)
+	if err != nil {
+		writeError(response, err)
+		return
+	}
+	fmt.Printf("Composition:%s\n", string(compositionInfo))
+
+	response.Write(compositionInfo)
+}
 
-	compositionInfo := discovery.TotalClusterCompositions.GetCompositionsString(resourceKind,
-																		  resourceInstance,
-																		  namespace)
-	fmt.Printf("Composition:%v\n", compositionInfo)
+// Maps errors from the discovery package to HTTP status codes.
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, discovery.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, discovery.ErrUnknownKind):
+		return http.StatusBadRequest
+	case errors.Is(err, discovery.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, discovery.ErrInvalidRelationship):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
 
-	response.Write([]byte(compositionInfo))
+func writeError(response *restful.Response, err error) {
+	fmt.Printf("Error:%s\n", err.Error())
+	response.WriteErrorString(statusForError(err), err.Error())
 }
 
 func getWebService() *restful.WebService {
@@ -307,7 +357,7 @@ func parseOpenAPISpec(openAPISpec []byte, customResourceKind string) string {
 	return retVal
 }
 
-func getCompositions(request *restful.Request, response *restful.Response) {
+func (h *kubePlusHandler) getCompositions(request *restful.Request, response *restful.Response) {
 	resourceName := request.PathParameter("resource-id")
 	requestPath := request.Request.URL.Path
 	fmt.Printf("Printing Composition\n")
@@ -321,8 +371,18 @@ func getCompositions(request *restful.Request, response *restful.Response) {
 	resourceNamespace := resourcePathSlice[5]
 	fmt.Printf("Resource Kind:%s, Resource name:%s\n", resourceKind, resourceName)
 
-	compositionsInfo := discovery.TotalClusterCompositions.GetCompositionsString(resourceKind, resourceName, resourceNamespace)
-	fmt.Printf("Compositions Info:%v", compositionsInfo)
+	ref := discovery.ObjectRef{Kind: resourceKind, Name: resourceName, Namespace: resourceNamespace}
+	compositions, err := h.d.Composition(request.Request.Context(), ref)
+	if err != nil {
+		writeError(response, err)
+		return
+	}
+	compositionsInfo, err := json.Marshal(compositions)
+	if err != nil {
+		writeError(response, err)
+		return
+	}
+	fmt.Printf("Compositions Info:%s", string(compositionsInfo))
 
-	response.Write([]byte(compositionsInfo))
+	response.Write(compositionsInfo)
 }
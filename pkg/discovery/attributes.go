@@ -0,0 +1,145 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Sets the Attributes of the objects in connections that have any: the
+// replicas of autoscalers and of the objects they scale, the budget of
+// PodDisruptionBudgets and the runs of Jobs and CronJobs. Objects that cannot
+// be read are left without.
+func (q *query) setAttributes(connections []Connection) {
+	for i := range connections {
+		conn := &connections[i]
+		gk := q.registry.groupKind(conn.Kind)
+		if !q.hasAttributes(gk) {
+			continue
+		}
+		obj, err := q.getKubeObject(conn.Kind, conn.Name, conn.Namespace, q.kindResource(conn.Kind))
+		if err != nil {
+			continue
+		}
+		attributes := q.objectAttributes(gk, &obj)
+		// Compositions show it as their Status
+		if gk == (schema.GroupKind{Group: "batch", Kind: JOB}) {
+			attributes["status"] = jobStatus(&obj)
+		}
+		if len(attributes) > 0 {
+			conn.Attributes = attributes
+		}
+	}
+}
+
+func (q *query) hasAttributes(gk schema.GroupKind) bool {
+	if _, scalable := q.registry.scaleReplicasMap[gk]; scalable {
+		return true
+	}
+	switch gk {
+	case schema.GroupKind{Group: "autoscaling", Kind: HPA}, schema.GroupKind{Group: "policy", Kind: PDB},
+		schema.GroupKind{Group: "batch", Kind: JOB}, schema.GroupKind{Group: "batch", Kind: CRON_JOB}:
+		return true
+	}
+	return false
+}
+
+func (q *query) objectAttributes(gk schema.GroupKind, obj *unstructured.Unstructured) map[string]string {
+	attributes := make(map[string]string)
+	content := obj.UnstructuredContent()
+	switch {
+	case gk == schema.GroupKind{Group: "autoscaling", Kind: HPA}:
+		// minReplicas defaults to 1
+		attributes["minReplicas"] = "1"
+		setAttribute(attributes, "minReplicas", content, "spec", "minReplicas")
+		setAttribute(attributes, "maxReplicas", content, "spec", "maxReplicas")
+		setAttribute(attributes, "currentReplicas", content, "status", "currentReplicas")
+		setAttribute(attributes, "desiredReplicas", content, "status", "desiredReplicas")
+	case gk == schema.GroupKind{Group: "policy", Kind: PDB}:
+		setAttribute(attributes, "minAvailable", content, "spec", "minAvailable")
+		setAttribute(attributes, "maxUnavailable", content, "spec", "maxUnavailable")
+		setAttribute(attributes, "currentHealthy", content, "status", "currentHealthy")
+		setAttribute(attributes, "desiredHealthy", content, "status", "desiredHealthy")
+		setAttribute(attributes, "expectedPods", content, "status", "expectedPods")
+		setAttribute(attributes, "disruptionsAllowed", content, "status", "disruptionsAllowed")
+	case gk == schema.GroupKind{Group: "batch", Kind: JOB}:
+		setAttribute(attributes, "active", content, "status", "active")
+		setAttribute(attributes, "succeeded", content, "status", "succeeded")
+		setAttribute(attributes, "failed", content, "status", "failed")
+		setAttribute(attributes, "startTime", content, "status", "startTime")
+		setAttribute(attributes, "completionTime", content, "status", "completionTime")
+	case gk == schema.GroupKind{Group: "batch", Kind: CRON_JOB}:
+		setAttribute(attributes, "schedule", content, "spec", "schedule")
+		setAttribute(attributes, "suspend", content, "spec", "suspend")
+		setAttribute(attributes, "lastScheduleTime", content, "status", "lastScheduleTime")
+		setAttribute(attributes, "lastSuccessfulTime", content, "status", "lastSuccessfulTime")
+		active, found, _ := unstructured.NestedSlice(content, "status", "active")
+		if found {
+			attributes["active"] = fmt.Sprintf("%d", len(active))
+		}
+	default:
+		// The replicas paths are JSONPaths like .spec.replicas
+		paths := q.registry.scaleReplicasMap[gk]
+		for i, name := range []string{"desiredReplicas", "currentReplicas"} {
+			if i < len(paths) && paths[i] != "" {
+				setAttribute(attributes, name, content, strings.Split(strings.TrimPrefix(paths[i], "."), ".")...)
+			}
+		}
+	}
+	return attributes
+}
+
+// Complete or Failed once the Job has finished, Suspended or Running before
+func jobStatus(obj *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(obj.UnstructuredContent(), "status", "conditions")
+	for _, item := range conditions {
+		condition, ok := item.(map[string]interface{})
+		if !ok || condition["status"] != "True" {
+			continue
+		}
+		if conditionType, _ := condition["type"].(string); conditionType == "Complete" || conditionType == "Failed" {
+			return conditionType
+		}
+	}
+	if suspend, _, _ := unstructured.NestedBool(obj.UnstructuredContent(), "spec", "suspend"); suspend {
+		return "Suspended"
+	}
+	return "Running"
+}
+
+func setAttribute(attributes map[string]string, name string, content map[string]interface{}, fields ...string) {
+	value, found, _ := unstructured.NestedFieldNoCopy(content, fields...)
+	if !found || value == nil {
+		return
+	}
+	// Lists such as accessModes as ReadWriteOnce,ReadOnlyMany
+	if list, ok := value.([]interface{}); ok {
+		values := make([]string, 0)
+		for _, item := range list {
+			values = append(values, fmt.Sprintf("%v", item))
+		}
+		attributes[name] = strings.Join(values, ",")
+		return
+	}
+	attributes[name] = fmt.Sprintf("%v", value)
+}
+
+// Attributes as shown after an object in text output, e.g. (desiredReplicas:3)
+func attributesString(attributes map[string]string) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+	names := make([]string, 0)
+	for name, _ := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, 0)
+	for _, name := range names {
+		values = append(values, name+":"+attributes[name])
+	}
+	return " (" + strings.Join(values, " ") + ")"
+}
@@ -0,0 +1,247 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8sdiscovery "k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// Options control how a Discoverer answers queries.
+type Options struct {
+	// Comma separated Kind:name or Kind:* entries that connections should not follow.
+	Ignore string
+	// If set, every node visited while discovering connections is reported here.
+	Progress io.Writer
+	// If set, objects are read from these manifests instead of the API server.
+	Manifests *Manifests
+	// Namespaces searched besides the namespace given in the ObjectRef:
+	// compositions are listed for all of them, and objects of cluster-scoped
+	// kinds are related to objects in any of them. A namespace of "" stands
+	// for all namespaces.
+	Namespaces []string
+	// If set, connections also follow fields shaped like object references
+	// that no relationship declares, such as {apiVersion, kind, name} or a
+	// credentialsSecretRef {name}. Such connections are marked Inferred.
+	InferReferences bool
+	// Files of RelationshipRule objects, read besides the RelationshipRules
+	// of the cluster or the manifests.
+	RuleFiles []string
+	// Rule packs to enable besides those whose CRDs are installed, see
+	// RulePacks. "none" stops packs from being enabled by their CRDs.
+	Packs []string
+}
+
+// ObjectRef identifies the object a query starts from. Kind may also be a
+// resource name, a short name or either qualified with the group, as in
+// "deploy", "deployments.apps" or "Deployment.apps".
+type ObjectRef struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// Discoverer answers composition and connections queries against a cluster,
+// or against a set of manifests. It holds no per-query state, so a single
+// Discoverer can serve concurrent queries.
+type Discoverer struct {
+	config  *rest.Config
+	client  dynamic.Interface
+	mapper  meta.RESTMapper
+	options Options
+}
+
+// NewDiscoverer returns a Discoverer for the cluster at config. config may be
+// nil when options.Manifests is set.
+func NewDiscoverer(config *rest.Config, options Options) (*Discoverer, error) {
+	d := &Discoverer{
+		config:  config,
+		options: options,
+	}
+	if options.Manifests != nil {
+		d.client = options.Manifests
+		return d, nil
+	}
+	if config == nil {
+		return nil, fmt.Errorf("no cluster configuration and no manifests given")
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	d.client = client
+	discoveryClient, err := k8sdiscovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	// API discovery is cached for the lifetime of the Discoverer, see kindRegistry.restMapping.
+	cachedClient := memory.NewMemCacheClient(discoveryClient)
+	deferred := restmapper.NewDeferredDiscoveryRESTMapper(cachedClient)
+	d.mapper = shortcutMapper{RESTMapper: restmapper.NewShortcutExpander(deferred, cachedClient), deferred: deferred}
+	return d, nil
+}
+
+// Expands short names from API discovery, such as "deploy", and can still
+// be reset when a CRD was created after discovery was cached.
+type shortcutMapper struct {
+	meta.RESTMapper
+	deferred *restmapper.DeferredDiscoveryRESTMapper
+}
+
+func (m shortcutMapper) Reset() {
+	m.deferred.Reset()
+}
+
+// State of a single composition or connections query.
+type query struct {
+	ctx      context.Context
+	d        *Discoverer
+	client   dynamic.Interface
+	registry *kindRegistry
+
+	compositions ClusterCompositions
+	connections  []Connection
+
+	listCache   map[KubeObjectCacheEntry]*unstructured.UnstructuredList
+	objectCache map[KubeObjectCacheEntry]*unstructured.Unstructured
+
+	// Inferred references by namespace, see inferredReferrers()
+	referrers map[string][]inferredReferrer
+
+	// Set to inputs given to connections
+	orig ObjectRef
+
+	// First error that stopped the query, see fail()
+	err error
+}
+
+func (d *Discoverer) newQuery(ctx context.Context) *query {
+	return &query{
+		ctx:          ctx,
+		d:            d,
+		client:       d.client,
+		registry:     newKindRegistry(d.mapper),
+		compositions: ClusterCompositions{},
+		connections:  make([]Connection, 0),
+		listCache:    make(map[KubeObjectCacheEntry]*unstructured.UnstructuredList),
+		objectCache:  make(map[KubeObjectCacheEntry]*unstructured.Unstructured),
+		referrers:    make(map[string][]inferredReferrer),
+	}
+}
+
+// Loads the kinds of the query and names ref.Kind, which may be given in any
+// form accepted by kindRegistry.resolveKind, the way the query does.
+func (q *query) resolveRef(ref ObjectRef) (ObjectRef, error) {
+	err := q.readKindCompositionFile()
+	if err != nil {
+		return ref, err
+	}
+	gk, err := q.registry.resolveKind(ref.Kind)
+	if err != nil {
+		return ref, err
+	}
+	ref.Kind = q.registry.kindName(gk)
+	return ref, nil
+}
+
+// Composition returns the composition trees of ref. A Name of "*" returns
+// the trees of all objects of ref.Kind in ref.Namespace.
+func (d *Discoverer) Composition(ctx context.Context, ref ObjectRef) ([]Composition, error) {
+	q := d.newQuery(ctx)
+	ref, err := q.resolveRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Name != "*" {
+		err = q.checkExistence(ref.Kind, ref.Name, ref.Namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = q.buildCompositionTree(ref.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	// All objects of ref.Kind are returned from every namespace of the query
+	namespaces := []string{q.kindNamespace(ref.Kind, ref.Namespace)}
+	if ref.Name == "*" && namespaces[0] != "" {
+		namespaces = q.queryNamespaces(ref.Namespace)
+		if namespaces[0] == metav1.NamespaceAll {
+			namespaces = q.compositions.namespaces()
+		}
+	}
+	compositions := make([]Composition, 0)
+	for _, namespace := range namespaces {
+		compositions = append(compositions, q.compositions.GetCompositions(ref.Kind, ref.Name, namespace)...)
+	}
+	return compositions, nil
+}
+
+// Connections returns every object reachable from ref through owner references,
+// labels, annotations, spec properties, environment variables and scheduling
+// constraints. The first entry is ref itself at level 0.
+func (d *Discoverer) Connections(ctx context.Context, ref ObjectRef) ([]Connection, error) {
+	q := d.newQuery(ctx)
+	ref, err := q.resolveRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	q.orig = ref
+	err = q.checkExistence(ref.Kind, ref.Name, ref.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	// Prefetching does not seem to improve performance.
+	// In fact, it degrades performance by few milliseconds.
+	// So turning pre-fetching off
+	//q.fetchGVKs(ref.Namespace)
+	level := 0
+	visited := make([]Connection, 0)
+	relationType := ""
+	// Build the composition tree
+	err = q.buildCompositionTree(ref.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	root := Connection{
+		Name:      ref.Name,
+		Kind:      ref.Kind,
+		Namespace: ref.Namespace,
+		Level:     level,
+		Peer: &Connection{
+			Name:      "",
+			Kind:      "",
+			Namespace: "",
+		},
+	}
+	q.connections = AppendConnections(q.connections, root)
+
+	level = level + 1
+	_ = q.getRelatives(visited, level, ref.Kind, ref.Name, ref.Kind, ref.Name, ref.Namespace, relationType)
+	if q.err != nil {
+		return nil, q.err
+	}
+	q.clearClusterNamespaces(q.connections)
+	q.setAttributes(q.connections)
+	return q.connections, nil
+}
+
+// Connections carry the namespace that was being searched when they were
+// found. Objects of cluster-scoped kinds are reported without one.
+func (q *query) clearClusterNamespaces(connections []Connection) {
+	for i := range connections {
+		conn := &connections[i]
+		conn.Namespace = q.kindNamespace(conn.Kind, conn.Namespace)
+		if conn.Peer != nil && conn.Peer.Kind != "" {
+			conn.Peer.Namespace = q.kindNamespace(conn.Peer.Kind, conn.Peer.Namespace)
+		}
+	}
+}
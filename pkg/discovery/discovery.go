@@ -1,15 +1,13 @@
 package discovery
 
 import (
-	"crypto/tls"
 	"context"
-	cert "crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
-	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"strconv"
 	"gopkg.in/yaml.v2"
@@ -22,43 +20,38 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
 
-var (
-	serviceHost    string
-	servicePort    string
+func (q *query) buildCompositionTree(namespace string) error {
+	namespaces := q.queryNamespaces(namespace)
 
-	masterURL   string
-	etcdservers string
-	caToken		[]byte
-	caCertPool	*cert.CertPool
-)
-
-func init() {
-}
-
-func BuildCompositionTree(namespace string) {
-	var namespaces []string
-	namespaces = append(namespaces, namespace)
-
-	err := readKindCompositionFile("")
+	err := q.readKindCompositionFile()
 	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
+		return err
 	}
-	resourceKindList := getResourceKinds()
+	resourceKindList := q.registry.getResourceKinds()
 
 	resourceInCluster := []MetaDataAndOwnerReferences{}
 	for _, resourceKind := range resourceKindList {
-		for _, namespace := range namespaces {
-			topLevelMetaDataOwnerRefList := getTopLevelResourceMetaData(resourceKind, namespace)
+		for i, namespace := range namespaces {
+			// Cluster-scoped kinds are listed once
+			if i > 0 && q.registry.clusterScoped(resourceKind) {
+				break
+			}
+			topLevelMetaDataOwnerRefList, err := q.getTopLevelResourceMetaData(resourceKind, q.kindNamespace(resourceKind, namespace))
+			if err != nil {
+				return err
+			}
 			for _, topLevelObject := range topLevelMetaDataOwnerRefList {
 				resourceName := topLevelObject.MetaDataName
 				namespace := topLevelObject.Namespace
 				level := 1
 				compositionTree := []CompositionTreeNode{}
 				//fmt.Printf("ResKind:%s ResName:%s\n", resourceKind, resourceName)
-				buildCompositions(resourceKind, resourceName, namespace, level, &compositionTree)
+				err = q.buildCompositions(resourceKind, resourceName, namespace, level, &compositionTree)
+				if err != nil {
+					return err
+				}
 				//fmt.Printf("CompositionTree:%v\n", compositionTree)
-				TotalClusterCompositions.storeCompositions(topLevelObject, resourceKind, resourceName, namespace, &compositionTree)
+				q.compositions.storeCompositions(topLevelObject, resourceKind, resourceName, namespace, &compositionTree)
 			}
 			for _, resource := range topLevelMetaDataOwnerRefList {
 				present := false
@@ -73,15 +66,15 @@ func BuildCompositionTree(namespace string) {
 			}
 		}
 	}
-	TotalClusterCompositions.purgeCompositionOfDeletedItems(resourceInCluster)
-}
-
-func ReadKinds(inputKind string) error {
-	err := readKindCompositionFile(inputKind)
-	return err
+	q.compositions.purgeCompositionOfDeletedItems(resourceInCluster)
+	return nil
 }
 
-func readKindCompositionFile(inputKind string) error {
+func (q *query) readKindCompositionFile() error {
+	if q.registry.loaded {
+		return nil
+	}
+	q.registry.loaded = true
 	filePath, ok := os.LookupEnv("KIND_COMPOSITION_FILE")
 	if ok {
 		yamlFile, err := ioutil.ReadFile(filePath)
@@ -100,61 +93,66 @@ func readKindCompositionFile(inputKind string) error {
 			composition := compositionObj.Composition
 			plural := compositionObj.Plural
 
-			KindPluralMap[kind] = plural
-			kindVersionMap[kind] = endpoint
-			compositionMap[kind] = composition
+			gk := schema.GroupKind{Group: endpointGroup(endpoint), Kind: kind}
+			q.registry.pluralMap[gk] = plural
+			q.registry.versionMap[gk] = endpoint
+			q.registry.compositionMap[gk] = composition
+			q.registry.clusterScopedMap[gk] = compositionObj.Scope == "Cluster"
+			for _, kind := range composition {
+				setRuleValue(q.registry.compositionSources, gk, kind, "KIND_COMPOSITION_FILE")
+			}
 		}
 	} else {
-		crdClient, err1 := apiextensionsclientset.NewForConfig(cfg)
-		if err1 != nil {
-			// Should we bail out here or just continue??
-			fmt.Printf("Cannot discover Custom Resource connections. But can do rest..")
-			return err
-		}
-		crdList, err := crdClient.CustomResourceDefinitions().List(context.TODO(),
-																   metav1.ListOptions{})
+		crdList, err := q.d.listCRDs(q.ctx)
 		if err != nil {
-			fmt.Errorf("Error:%s\n", err)
 			return err
 		}
-		for _, crd := range crdList.Items {
-			crdName := crd.ObjectMeta.Name
-			//fmt.Printf("CRD NAME:%s\n", crdName)
-			crdObj, err := crdClient.CustomResourceDefinitions().Get(context.TODO(),
-													     			 crdName, 
-																	 metav1.GetOptions{})
-			if err != nil {
-				fmt.Errorf("Error:%s\n", err)
-				fmt.Printf("Cannot discover Custom Resource connections. But can do rest..")
-				//panic(err)
-				return err
-			}
-			//fmt.Printf("InputKind:%s, thisKind:%s\n", inputKind, crdObj.Spec.Names.Kind)
-			/*if inputKind != "" {
-				if inputKind == crdObj.Spec.Names.Kind {
-					parseCRDAnnotions(crdObj)
-					break
-				}
-			} else {
-				parseCRDAnnotions(crdObj)
-			}*/
-			parseCRDAnnotions(crdObj)
+		for _, crdObj := range crdList {
+			q.registry.parseCRDAnnotions(crdObj)
 		}
 	}
-	return nil
+	return q.readRelationshipRules()
+}
+
+func (d *Discoverer) listCRDs(ctx context.Context) ([]*apiextensionsv1beta1.CustomResourceDefinition, error) {
+	if d.options.Manifests != nil {
+		return d.options.Manifests.crds()
+	}
+	crdClient, err := apiextensionsclientset.NewForConfig(d.config)
+	if err != nil {
+		return nil, err
+	}
+	crdList, err := crdClient.CustomResourceDefinitions().List(ctx,
+															   metav1.ListOptions{})
+	if err != nil {
+		return nil, fromAPIError(err)
+	}
+	crds := make([]*apiextensionsv1beta1.CustomResourceDefinition, 0)
+	for i := range crdList.Items {
+		crds = append(crds, &crdList.Items[i])
+	}
+	return crds, nil
 }
 
-func parseCRDAnnotions(crdObj *apiextensionsv1beta1.CustomResourceDefinition) {
+func (r *kindRegistry) parseCRDAnnotions(crdObj *apiextensionsv1beta1.CustomResourceDefinition) {
 
 	//fmt.Printf("Inside parseCRDAnnotions\n")
 	group := crdObj.Spec.Group
 	version := crdObj.Spec.Versions[0].Name
 	endpoint := "apis/" + group + "/" + version
-	kind := crdObj.Spec.Names.Kind
+	gk := schema.GroupKind{Group: group, Kind: crdObj.Spec.Names.Kind}
 	plural := crdObj.Spec.Names.Plural
-	KindPluralMap[kind] = plural
-	kindVersionMap[kind] = endpoint
-	kindGroupMap[kind] = group
+	r.pluralMap[gk] = plural
+	r.versionMap[gk] = endpoint
+	for _, shortName := range crdObj.Spec.Names.ShortNames {
+		if !containsString(r.shortNames[gk], shortName) {
+			r.shortNames[gk] = append(r.shortNames[gk], shortName)
+		}
+	}
+	r.clusterScopedMap[gk] = crdObj.Spec.Scope == apiextensionsv1beta1.ClusterScoped
+	if singular := crdObj.Spec.Names.Singular; singular != "" {
+		r.shortNames[gk] = append(r.shortNames[gk], singular)
+	}
 
 	objectMeta := crdObj.ObjectMeta
 	annotations := objectMeta.GetAnnotations()
@@ -167,13 +165,39 @@ func parseCRDAnnotions(crdObj *apiextensionsv1beta1.CustomResourceDefinition) {
 	} 
 
 	componentKinds := strings.Split(compositionAnnotation, ",")
-	compositionMap[kind] = componentKinds
-	crdcompositionMap[kind] = componentKinds
+	r.compositionMap[gk] = componentKinds
+	r.crdcompositionMap[gk] = componentKinds
+	source := "CustomResourceDefinition/" + crdObj.Name
+	for _, kind := range componentKinds {
+		setRuleValue(r.compositionSources, gk, kind, source)
+	}
 
 	//fmt.Printf("=====\n")
 	allRels := getAllRelationships(annotations)
 	//printRels(allRels)
-	relationshipMap[kind] = allRels
+	// Built-in relationships of kinds that are CRDs, like VerticalPodAutoscaler, stay
+	r.relationshipMap[gk] = mergeRels(r.relationshipMap[gk], allRels)
+	for _, relString := range allRels {
+		setRuleValue(r.relationshipSources, gk, relString, source)
+	}
+
+	// Kinds with a scale subresource can be the target of autoscalers
+	for _, version := range crdObj.Spec.Versions {
+		if version.Subresources == nil || version.Subresources.Scale == nil {
+			continue
+		}
+		scale := version.Subresources.Scale
+		r.scaleReplicasMap[gk] = []string{scale.SpecReplicasPath, scale.StatusReplicasPath}
+		hpaKind := schema.GroupKind{Group: "autoscaling", Kind: HPA}
+		vpaKind := schema.GroupKind{Group: "autoscaling.k8s.io", Kind: VPA}
+		hpaRel := "specproperty, on:INSTANCE.spec.scaleTargetRef.name, value:" + gk.Kind + ".metadata.name"
+		vpaRel := "specproperty, on:INSTANCE.spec.targetRef.name, value:" + gk.Kind + ".metadata.name"
+		r.relationshipMap[hpaKind] = append(r.relationshipMap[hpaKind], hpaRel)
+		r.relationshipMap[vpaKind] = append(r.relationshipMap[vpaKind], vpaRel)
+		setRuleValue(r.relationshipSources, hpaKind, hpaRel, source)
+		setRuleValue(r.relationshipSources, vpaKind, vpaRel, source)
+		break
+	}
 }
 
 func getAllRelationships(annotations map[string]string) []string {
@@ -211,18 +235,18 @@ func mergeRels(allRels, relsToAdd []string) []string {
 
 func parseRels(annotations map[string]string, rel, relType string) []string {
 	rels := make([]string,0)
-	count := 1
-	for key, value := range annotations {
-		extendedKey := rel+strconv.Itoa(count)
-		//fmt.Printf("ExtendedKey:%s\n", extendedKey)
-		//fmt.Printf("key:%s, rel:%s, extendedKey:%s\n", key, rel, extendedKey)
-		if key == rel || key == extendedKey {
+	keys := make([]string, 0)
+	for key, _ := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := annotations[key]
+		//fmt.Printf("key:%s, rel:%s\n", key, rel)
+		if isRelationshipKey(key, rel) {
 			//fmt.Printf("(%s, %s)\n", key, value)
 			newRelValue := relType + ", " + value
 			rels = append(rels, newRelValue)
-			if key == extendedKey {
-				count = count + 1
-			}
 			//fmt.Printf("%s,", relValue)
 		}
 	}
@@ -231,39 +255,52 @@ func parseRels(annotations map[string]string, rel, relType string) []string {
 	return rels
 }
 
-func getResourceKinds() []string {
+// A relationship can be declared more than once by numbering the
+// annotation: resource/label-relationship1, resource/label-relationship2, ...
+func isRelationshipKey(key, rel string) bool {
+	if key == rel {
+		return true
+	}
+	if !strings.HasPrefix(key, rel) {
+		return false
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(key, rel))
+	return err == nil
+}
+
+func (r *kindRegistry) getResourceKinds() []string {
 	resourceKindSlice := make([]string, 0)
 	//resourceKindSlice = append(resourceKindSlice, "MysqlService")
-	for key, _ := range compositionMap {
-		resourceKindSlice = append(resourceKindSlice, key)
+	for key, _ := range r.compositionMap {
+		resourceKindSlice = append(resourceKindSlice, r.kindName(key))
 	}
 	return resourceKindSlice
 }
 
-func getResourceMetaData(resourceKindPlural, resourceGroup, resourceApiVersion,
+func (q *query) getResourceMetaData(resourceKindPlural, resourceGroup, resourceApiVersion,
 						 parentResKind, parentResName,
-						 namespace string) []MetaDataAndOwnerReferences {
+						 namespace string) ([]MetaDataAndOwnerReferences, error) {
 
 	metaDataAndOwnerReferenceList := []MetaDataAndOwnerReferences{}
 
 	//fmt.Printf("Res:%s, Group:%s, Version:%s\n", resourceKindPlural, resourceGroup, apiPart)
 
 	if resourceKindPlural == "" || resourceApiVersion == "" {
-		return metaDataAndOwnerReferenceList
-	}
-
-	dynamicClient, err := getDynamicClient()
-	if err != nil {
-		return metaDataAndOwnerReferenceList
+		return metaDataAndOwnerReferenceList, nil
 	}
 
 	res := schema.GroupVersionResource{Group: resourceGroup,
 									   Version: resourceApiVersion,
 									   Resource: resourceKindPlural}
 
-	list, err := dynamicClient.Resource(res).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	list, err := q.client.Resource(res).Namespace(namespace).List(q.ctx, metav1.ListOptions{})
 	if err != nil {
-		return metaDataAndOwnerReferenceList
+		err = fromAPIError(err)
+		// The kind is known but not served, e.g. its CRD was deleted
+		if errors.Is(err, ErrNotFound) {
+			return metaDataAndOwnerReferenceList, nil
+		}
+		return metaDataAndOwnerReferenceList, err
 	}
 
 	for _, unstructuredObj := range list.Items {
@@ -286,27 +323,36 @@ func getResourceMetaData(resourceKindPlural, resourceGroup, resourceApiVersion,
 		if found {
 			metaDataRef.Status = phase
 		}
+		gk := unstructuredObj.GroupVersionKind().GroupKind()
+		if gk == (schema.GroupKind{Group: "batch", Kind: JOB}) {
+			metaDataRef.Status = jobStatus(&unstructuredObj)
+		}
+		if q.hasAttributes(gk) {
+			attributes := q.objectAttributes(gk, &unstructuredObj)
+			if len(attributes) > 0 {
+				metaDataRef.Attributes = attributes
+			}
+		}
 
 		//if metaDataRef.OwnerReferenceKind != "" && metaDataRef.OwnerReferenceName != "" {
 			metaDataAndOwnerReferenceList = append(metaDataAndOwnerReferenceList, metaDataRef)
 		//}
 	}
 
-	return metaDataAndOwnerReferenceList
+	return metaDataAndOwnerReferenceList, nil
 }
 
-func getTopLevelResourceMetaData(resourceKind, namespace string) []MetaDataAndOwnerReferences {
-	resourceKindPlural, _, resourceApiVersion, resourceGroup := getKindAPIDetails(resourceKind)
+func (q *query) getTopLevelResourceMetaData(resourceKind, namespace string) ([]MetaDataAndOwnerReferences, error) {
+	resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(resourceKind)
 
 	parentResKind := ""
 	parentResName := ""
-	metaDataAndOwnerReferenceList := getResourceMetaData(resourceKindPlural,
-														 resourceGroup,
-														 resourceApiVersion,
-														 parentResKind,
-														 parentResName,
-														 namespace)
-	return metaDataAndOwnerReferenceList
+	return q.getResourceMetaData(resourceKindPlural,
+								 resourceGroup,
+								 resourceApiVersion,
+								 parentResKind,
+								 parentResName,
+								 namespace)
 }
 
 func processed(processedList *[]CompositionTreeNode, nodeToCheck CompositionTreeNode) bool {
@@ -319,7 +365,7 @@ func processed(processedList *[]CompositionTreeNode, nodeToCheck CompositionTree
 	return result
 }
 
-func getComposition(kind, name, namespace, status string, level int, compositionTree *[]CompositionTreeNode,
+func getComposition(kind, name, namespace, status string, attributes map[string]string, level int, compositionTree *[]CompositionTreeNode,
 	processedList *[]CompositionTreeNode) Composition {
 	parentComposition := Composition{}
 	parentComposition.Level = level
@@ -327,6 +373,7 @@ func getComposition(kind, name, namespace, status string, level int, composition
 	parentComposition.Name = name
 	parentComposition.Namespace = namespace
 	parentComposition.Status = status
+	parentComposition.Attributes = attributes
 	parentComposition.Children = []Composition{}
 
 	for _, compositionTreeNode := range *compositionTree {
@@ -351,7 +398,7 @@ func getComposition(kind, name, namespace, status string, level int, composition
 				}
 			}
 			*processedList = append(*processedList, compositionTreeNode)
-			child := getComposition(childKind, childName, childNamespace, childStatus, level, &trimmedTree, processedList)
+			child := getComposition(childKind, childName, childNamespace, childStatus, metaDataNode.Attributes, level, &trimmedTree, processedList)
 			parentComposition.Children = append(parentComposition.Children, child)
 			compositionTree = &[]CompositionTreeNode{}
 		}
@@ -359,13 +406,26 @@ func getComposition(kind, name, namespace, status string, level int, composition
 	return parentComposition
 }
 
+// Namespaces that compositions were stored for.
+func (cp *ClusterCompositions) namespaces() []string {
+	cp.mux.Lock()
+	defer cp.mux.Unlock()
+	namespaces := make([]string, 0)
+	for _, compositionItem := range cp.clusterCompositions {
+		if !containsString(namespaces, compositionItem.Namespace) {
+			namespaces = append(namespaces, compositionItem.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
 func (cp *ClusterCompositions) GetCompositions(resourceKind, resourceName, namespace string) []Composition {
 	cp.mux.Lock()
 	defer cp.mux.Unlock()
 	//var compositionBytes []byte
 	//var compositionString string
 	compositions := []Composition{}
-	resourceKindPlural := KindPluralMap[resourceKind]
 	//fmt.Println("Compositions of different Kinds in this Cluster")
 	//fmt.Printf("Kind:%s, Name:%s\n", resourceKindPlural, resourceName)
 	for _, compositionItem := range cp.clusterCompositions {
@@ -374,18 +434,8 @@ func (cp *ClusterCompositions) GetCompositions(resourceKind, resourceName, names
 		nmspace := strings.ToLower(compositionItem.Namespace)
 		status := compositionItem.Status
 		compositionTree := compositionItem.CompositionTree
-		resourceKindPlural := strings.ToLower(resourceKindPlural)
-		//TODO(devdattakulkarni): Make route registration and compositions keyed info
-		//to use same kind name (plural). Currently Compositions info is keyed on
-		//singular kind names. For now, trimming the 's' at the end
-		//resourceKind = strings.TrimSuffix(resourceKind, "s")
-		var resourceKind string
-		for key, value := range KindPluralMap {
-			if strings.ToLower(value) == strings.ToLower(resourceKindPlural) {
-				resourceKind = strings.ToLower(key)
-				break
-			}
-		}
+		// Compositions are stored under the kind names of the registry
+		resourceKind := strings.ToLower(resourceKind)
 		resourceName := strings.ToLower(resourceName)
 		//fmt.Printf("Kind:%s, Kind:%s, Name:%s, Name:%s\n", kind, resourceKind, name, resourceName)
 
@@ -397,13 +447,13 @@ func (cp *ClusterCompositions) GetCompositions(resourceKind, resourceName, names
 		case resourceName == "*" && resourceKind == kind && namespace == nmspace:
 			processedList := []CompositionTreeNode{}
 			level := 1
-			composition := getComposition(kind, name, namespace, status, level, compositionTree, &processedList)
+			composition := getComposition(kind, name, namespace, status, compositionItem.Attributes, level, compositionTree, &processedList)
 			compositions = append(compositions, composition)
 			break
 		case resourceName == name && resourceKind == kind && namespace == nmspace:
 			processedList := []CompositionTreeNode{}
 			level := 1
-			composition := getComposition(kind, name, namespace, status, level, compositionTree, &processedList)
+			composition := getComposition(kind, name, namespace, status, compositionItem.Attributes, level, compositionTree, &processedList)
 			compositions = append(compositions, composition)
 			break
 		}
@@ -452,6 +502,7 @@ func (cp *ClusterCompositions) storeCompositions(topLevelObject MetaDataAndOwner
 		Name:            resourceName,
 		Namespace:       namespace,
 		Status:          topLevelObject.Status,
+		Attributes:      topLevelObject.Attributes,
 		CompositionTree: compositionTree,
 	}
 	present := false
@@ -463,6 +514,7 @@ func (cp *ClusterCompositions) storeCompositions(topLevelObject MetaDataAndOwner
 			//fmt.Printf("CompositionTree:%v\n", compositionTree)
 			p.CompositionTree = compositionTree
 			p.Status = topLevelObject.Status
+			p.Attributes = topLevelObject.Attributes
 			cp.clusterCompositions[i] = *p
 			//fmt.Printf("11 CP:%v\n", cp.clusterCompositions)
 		}
@@ -472,25 +524,28 @@ func (cp *ClusterCompositions) storeCompositions(topLevelObject MetaDataAndOwner
 	}
 }
 
-func buildCompositions(parentResourceKind string, parentResourceName string, parentNamespace string, level int,
-	compositionTree *[]CompositionTreeNode) {
-	childResourceKindList, present := compositionMap[parentResourceKind]
+func (q *query) buildCompositions(parentResourceKind string, parentResourceName string, parentNamespace string, level int,
+	compositionTree *[]CompositionTreeNode) error {
+	parentGroupKind := q.registry.groupKind(parentResourceKind)
+	childResourceKindList, present := q.registry.compositionMap[parentGroupKind]
 	if present {
 		level = level + 1
 
 		for _, childResourceKind := range childResourceKindList {
-			childResourceKind = strings.TrimSpace(childResourceKind)
-			childKindPlural, _, childResourceApiVersion, childResourceGroup := getKindAPIDetails(childResourceKind)
+			childResourceKind = q.registry.relatedKind(childResourceKind, parentGroupKind)
+			childKindPlural, _, childResourceApiVersion, childResourceGroup := q.registry.getKindAPIDetails(childResourceKind)
 
+			// Children of a cluster-scoped parent may be in any namespace
 			//var content []byte
-			var metaDataAndOwnerReferenceList []MetaDataAndOwnerReferences
-
-			metaDataAndOwnerReferenceList = getResourceMetaData(childKindPlural,
-																childResourceGroup,
-																childResourceApiVersion,
-																parentResourceKind,
-																parentResourceName,
-																parentNamespace)
+			metaDataAndOwnerReferenceList, err := q.getResourceMetaData(childKindPlural,
+																	  childResourceGroup,
+																	  childResourceApiVersion,
+																	  parentResourceKind,
+																	  parentResourceName,
+																	  q.kindNamespace(childResourceKind, parentNamespace))
+			if err != nil {
+				return err
+			}
 
 			childrenList := filterChildren(&metaDataAndOwnerReferenceList, parentResourceName)
 			compTreeNode := CompositionTreeNode{
@@ -504,80 +559,36 @@ func buildCompositions(parentResourceKind string, parentResourceName string, par
 			for _, metaDataRef := range childrenList {
 				resourceName := metaDataRef.MetaDataName
 				resourceKind := childResourceKind
-				buildCompositions(resourceKind, resourceName, parentNamespace, level, compositionTree)
+				err = q.buildCompositions(resourceKind, resourceName, parentNamespace, level, compositionTree)
+				if err != nil {
+					return err
+				}
 			}
 		}
-	} else {
-		return
 	}
+	return nil
 }
 
-func getAllNamespaces() []string {
-	var url1 string
-	url1 = fmt.Sprintf("https://%s:%s/%s/namespaces", serviceHost, servicePort, "api/v1")
-	//fmt.Printf("URL:%s\n", url1)
-	responseBody := queryAPIServer(url1)
-	return parseNamespacesResponse(responseBody)
-}
-
-func (cp *ClusterCompositions) QueryResource(resourceKind, resourceName, namespace string) []byte {
-	resourceApiVersion := kindVersionMap[resourceKind]
-	resourceKindPlural := KindPluralMap[resourceKind]
-	url1 := fmt.Sprintf("https://%s:%s/%s/namespaces/%s/%s/%s", serviceHost, servicePort, resourceApiVersion, namespace, resourceKindPlural, resourceName)
-	fmt.Printf("Resource Query URL:%s\n", url1)
-	contents := queryAPIServer(url1)
-	return contents
-}
-
-func queryAPIServer(url1 string) []byte {
-	if caToken != nil {
-		caToken = getToken()
-	}
-	if caCertPool != nil {
-		caCertPool = getCACert()
-	}
-
-	u, err := url.Parse(url1)
+// QueryResource returns the JSON representation of the object at ref.
+func (d *Discoverer) QueryResource(ctx context.Context, ref ObjectRef) ([]byte, error) {
+	q := d.newQuery(ctx)
+	ref, err := q.resolveRef(ref)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
-	req.Close = true // to ensure that the connection is closed
+	err = q.checkExistence(ref.Kind, ref.Name, ref.Namespace)
 	if err != nil {
-		fmt.Println(err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", string(caToken)))
-	var client *http.Client
-
-	if caCertPool != nil {
-		client = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs: caCertPool,
-				},
-			},
-		}
-	} else {
-		client = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: true,
-				},
-			},
-		}
+		return nil, err
 	}
-
-	resp, err := client.Do(req)
+	resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(ref.Kind)
+	res := schema.GroupVersionResource{Group: resourceGroup,
+									   Version: resourceApiVersion,
+									   Resource: resourceKindPlural}
+	obj, err := q.getKubeObject(ref.Kind, ref.Name, ref.Namespace, res)
 	if err != nil {
-		fmt.Printf("sending request failed: %s", err.Error())
-		fmt.Println(err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	resp_body, _ := ioutil.ReadAll(resp.Body)
-
-	return resp_body
+	return obj.MarshalJSON()
 }
 
 func filterChildren(metaDataSlice *[]MetaDataAndOwnerReferences, parentResourceName string) []MetaDataAndOwnerReferences {
@@ -598,50 +609,3 @@ func filterChildren(metaDataSlice *[]MetaDataAndOwnerReferences, parentResourceN
 	}
 	return metaDataSliceToReturn
 }
-
-func parseNamespacesResponse(content []byte) []string {
-	var result map[string]interface{}
-	json.Unmarshal([]byte(content), &result)
-	namespaces := make([]string, 0)
-	items, ok := result["items"].([]interface{})
-
-	if ok {
-		for _, item := range items {
-			itemConverted := item.(map[string]interface{})
-			for key, value := range itemConverted {
-				if key == "metadata" {
-					metadataMap := value.(map[string]interface{})
-					for mkey, mvalue := range metadataMap {
-						if mkey == "name" {
-							namespace := mvalue.(string)
-							namespaces = append(namespaces, namespace)
-						}
-					}
-				}
-			}
-		}
-	}
-	return namespaces
-}
-
-// Ref:https://stackoverflow.com/questions/30690186/how-do-i-access-the-kubernetes-api-from-within-a-pod-container
-func getToken() []byte {
-	caToken, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/token")
-	if err != nil {
-		panic(err) // cannot find token file
-	}
-	//fmt.Printf("Token:%s", caToken)
-	return caToken
-}
-
-// Ref:https://stackoverflow.com/questions/30690186/how-do-i-access-the-kubernetes-api-from-within-a-pod-container
-func getCACert() *cert.CertPool {
-	caCertPool := cert.NewCertPool()
-	caCert, err := ioutil.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/ca.crt")
-	if err != nil {
-		panic(err) // Can't find cert file
-	}
-	//fmt.Printf("CaCert:%s",caCert)
-	caCertPool.AppendCertsFromPEM(caCert)
-	return caCertPool
-}
@@ -0,0 +1,337 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// EnvVar is an environment variable of a container of a Pod and the object
+// and key its value comes from.
+type EnvVar struct {
+	Container string
+	// containers, initContainers or ephemeralContainers
+	ContainerType string
+	Name string
+	// Values read from Secrets are not shown
+	Value string
+	// value, ConfigMap, Secret, fieldRef or resourceFieldRef
+	Source string
+	// Name of the ConfigMap or Secret, or the container of a resourceFieldRef
+	SourceName string
+	// Key in the ConfigMap or Secret, fieldPath of a fieldRef, resource of a resourceFieldRef
+	Key string
+	// The env or envFrom entry that sets the variable, e.g. spec.containers[0].envFrom[1]
+	Path string
+	// The ConfigMap, Secret or key does not exist. An envFrom source that
+	// does not exist is reported with an empty Name.
+	Missing bool
+	Optional bool
+}
+
+// An env or envFrom entry of a container
+type envSource struct {
+	containerType string
+	container string
+	path string
+	// Empty for envFrom entries
+	name string
+	prefix string
+	value string
+	// value, ConfigMap, Secret, fieldRef or resourceFieldRef
+	kind string
+	// ConfigMap or Secret name, container of a resourceFieldRef
+	ref string
+	key string
+	optional bool
+}
+
+var containerTypes = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// The env and envFrom entries of every container of a Pod, in the order the
+// kubelet reads them: envFrom before env.
+func podEnvSources(obj *unstructured.Unstructured) []envSource {
+	sources := make([]envSource, 0)
+	for _, containerType := range containerTypes {
+		containerList, _, _ := unstructured.NestedSlice(obj.UnstructuredContent(), "spec", containerType)
+		for i, cont := range containerList {
+			container, ok := cont.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			containerName, _, _ := unstructured.NestedString(container, "name")
+			at := fmt.Sprintf("spec.%s[%d]", containerType, i)
+
+			envFromList, _, _ := unstructured.NestedSlice(container, "envFrom")
+			for j, item := range envFromList {
+				envFrom, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				source := envSource{containerType: containerType, container: containerName, path: fmt.Sprintf("%s.envFrom[%d]", at, j)}
+				source.prefix, _, _ = unstructured.NestedString(envFrom, "prefix")
+				if ref, ok := envFrom["configMapRef"].(map[string]interface{}); ok {
+					source.kind = CONFIG_MAP
+					source.ref, _, _ = unstructured.NestedString(ref, "name")
+					source.optional, _, _ = unstructured.NestedBool(ref, "optional")
+				} else if ref, ok := envFrom["secretRef"].(map[string]interface{}); ok {
+					source.kind = SECRET
+					source.ref, _, _ = unstructured.NestedString(ref, "name")
+					source.optional, _, _ = unstructured.NestedBool(ref, "optional")
+				} else {
+					continue
+				}
+				sources = append(sources, source)
+			}
+
+			envVarList, _, _ := unstructured.NestedSlice(container, "env")
+			for j, item := range envVarList {
+				envVar, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				source := envSource{containerType: containerType, container: containerName, path: fmt.Sprintf("%s.env[%d]", at, j), kind: "value"}
+				source.name, _, _ = unstructured.NestedString(envVar, "name")
+				source.value, _, _ = unstructured.NestedString(envVar, "value")
+				valueFrom, _, _ := unstructured.NestedMap(envVar, "valueFrom")
+				if ref, ok := valueFrom["configMapKeyRef"].(map[string]interface{}); ok {
+					source.kind = CONFIG_MAP
+					source.ref, _, _ = unstructured.NestedString(ref, "name")
+					source.key, _, _ = unstructured.NestedString(ref, "key")
+					source.optional, _, _ = unstructured.NestedBool(ref, "optional")
+				} else if ref, ok := valueFrom["secretKeyRef"].(map[string]interface{}); ok {
+					source.kind = SECRET
+					source.ref, _, _ = unstructured.NestedString(ref, "name")
+					source.key, _, _ = unstructured.NestedString(ref, "key")
+					source.optional, _, _ = unstructured.NestedBool(ref, "optional")
+				} else if ref, ok := valueFrom["fieldRef"].(map[string]interface{}); ok {
+					source.kind = "fieldRef"
+					source.key, _, _ = unstructured.NestedString(ref, "fieldPath")
+				} else if ref, ok := valueFrom["resourceFieldRef"].(map[string]interface{}); ok {
+					source.kind = "resourceFieldRef"
+					source.ref, _, _ = unstructured.NestedString(ref, "containerName")
+					if source.ref == "" {
+						source.ref = containerName
+					}
+					source.key, _, _ = unstructured.NestedString(ref, "resource")
+				}
+				sources = append(sources, source)
+			}
+		}
+	}
+	return sources
+}
+
+// The object of targetKind an env source refers to, if any. Literal values
+// may name a Service, in the forms accepted by envReference.
+func (q *query) envSourceReference(source envSource, targetKind string) (fieldReference, bool) {
+	switch {
+	case source.kind == "value":
+		if targetKind == CONFIG_MAP || targetKind == SECRET {
+			return fieldReference{}, false
+		}
+		return q.envReference(targetKind, source.value), true
+	case source.kind == targetKind && (targetKind == CONFIG_MAP || targetKind == SECRET):
+		return fieldReference{Value: source.ref}, true
+	}
+	return fieldReference{}, false
+}
+
+// RelationDetails of an env connection
+func envSourceDetail(source envSource) string {
+	switch {
+	case source.kind == "value":
+		return "Name:" + source.name + " " + "Value:" + source.value
+	case source.name == "":
+		detail := "Name:envFrom" + " " + "Value:" + source.ref
+		if source.prefix != "" {
+			detail = detail + " " + "Prefix:" + source.prefix
+		}
+		return detail
+	}
+	return "Name:" + source.name + " " + "Key:" + source.key
+}
+
+// Env returns the environment variables of every container of the Pod ref.
+// Variables set by envFrom are listed one by one, and a variable set twice
+// is listed once with the source that wins.
+func (d *Discoverer) Env(ctx context.Context, ref ObjectRef) ([]EnvVar, error) {
+	q := d.newQuery(ctx)
+	if ref.Kind == "" {
+		ref.Kind = POD
+	}
+	ref, err := q.resolveRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if ref.Kind != POD {
+		return nil, fmt.Errorf("env is shown for Pods, not %s", ref.Kind)
+	}
+	pod, err := q.getKubeObject(POD, ref.Name, ref.Namespace, q.kindResource(POD))
+	if errors.Is(err, ErrNotFound) {
+		return nil, newError(ErrNotFound, "Resource %s of kind %s in namespace %s does not exist.", ref.Name, POD, ref.Namespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// ConfigMaps and Secrets by kind/name, nil when they do not exist
+	sourceObjects := make(map[string]*unstructured.Unstructured)
+	getSource := func(kind, name string) (*unstructured.Unstructured, error) {
+		if obj, ok := sourceObjects[kind+"/"+name]; ok {
+			return obj, nil
+		}
+		obj, err := q.getKubeObject(kind, name, ref.Namespace, q.kindResource(kind))
+		if errors.Is(err, ErrNotFound) {
+			sourceObjects[kind+"/"+name] = nil
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		sourceObjects[kind+"/"+name] = &obj
+		return &obj, nil
+	}
+
+	envVars := make([]EnvVar, 0)
+	// Index in envVars of the variables of the current container
+	index := make(map[string]int)
+	container := ""
+	set := func(envVar EnvVar) {
+		if i, ok := index[envVar.Name]; ok && envVar.Name != "" {
+			envVars[i] = envVar
+			return
+		}
+		index[envVar.Name] = len(envVars)
+		envVars = append(envVars, envVar)
+	}
+	for _, source := range podEnvSources(&pod) {
+		if source.containerType+"/"+source.container != container {
+			container = source.containerType + "/" + source.container
+			index = make(map[string]int)
+		}
+		envVar := EnvVar{
+			Container: source.container,
+			ContainerType: source.containerType,
+			Name: source.name,
+			Value: source.value,
+			Source: source.kind,
+			SourceName: source.ref,
+			Key: source.key,
+			Path: source.path,
+			Optional: source.optional,
+		}
+		switch source.kind {
+		case CONFIG_MAP, SECRET:
+			obj, err := getSource(source.kind, source.ref)
+			if err != nil {
+				return nil, err
+			}
+			data := envSourceData(obj)
+			if source.name == "" {
+				// envFrom
+				if obj == nil {
+					envVar.Missing = true
+					envVars = append(envVars, envVar)
+					continue
+				}
+				keys := make([]string, 0)
+				for key, _ := range data {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					// The kubelet skips keys that are not valid variable names
+					if len(validation.IsEnvVarName(source.prefix+key)) > 0 {
+						continue
+					}
+					fromVar := envVar
+					fromVar.Name = source.prefix + key
+					fromVar.Key = key
+					if source.kind == CONFIG_MAP {
+						fromVar.Value = data[key]
+					}
+					set(fromVar)
+				}
+				continue
+			}
+			value, ok := data[source.key]
+			envVar.Missing = !ok
+			if source.kind == CONFIG_MAP {
+				envVar.Value = value
+			}
+		case "fieldRef":
+			envVar.Value = podFieldValue(&pod, source.key)
+		case "resourceFieldRef":
+			envVar.Value = containerResourceValue(&pod, source.ref, source.key)
+		}
+		set(envVar)
+	}
+	return envVars, nil
+}
+
+func (q *query) kindResource(kind string) schema.GroupVersionResource {
+	resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(kind)
+	return schema.GroupVersionResource{Group: resourceGroup,
+									   Version: resourceApiVersion,
+									   Resource: resourceKindPlural}
+}
+
+// Keys of a ConfigMap or Secret with their values. Secret values are left
+// out, binaryData keys of a ConfigMap have no value either.
+func envSourceData(obj *unstructured.Unstructured) map[string]string {
+	data := make(map[string]string)
+	if obj == nil {
+		return data
+	}
+	for _, field := range []string{"binaryData", "data", "stringData"} {
+		values, _, _ := unstructured.NestedMap(obj.UnstructuredContent(), field)
+		for key, value := range values {
+			data[key] = ""
+			if stringval, ok := value.(string); ok && obj.GetKind() == CONFIG_MAP {
+				data[key] = stringval
+			}
+		}
+	}
+	return data
+}
+
+// Value of a fieldRef, e.g. metadata.name or metadata.labels['app']
+func podFieldValue(pod *unstructured.Unstructured, fieldPath string) string {
+	for _, field := range []string{"labels", "annotations"} {
+		prefix := "metadata." + field + "["
+		if strings.HasPrefix(fieldPath, prefix) && strings.HasSuffix(fieldPath, "]") {
+			key := strings.Trim(fieldPath[len(prefix):len(fieldPath)-1], "'\"")
+			values, _, _ := unstructured.NestedStringMap(pod.UnstructuredContent(), "metadata", field)
+			return values[key]
+		}
+	}
+	value, _, _ := unstructured.NestedString(pod.UnstructuredContent(), strings.Split(fieldPath, ".")...)
+	return value
+}
+
+// Value of a resourceFieldRef such as limits.cpu when the container sets it
+func containerResourceValue(pod *unstructured.Unstructured, containerName, resource string) string {
+	for _, containerType := range containerTypes {
+		containerList, _, _ := unstructured.NestedSlice(pod.UnstructuredContent(), "spec", containerType)
+		for _, cont := range containerList {
+			container, ok := cont.(map[string]interface{})
+			if !ok || container["name"] != containerName {
+				continue
+			}
+			fields := append([]string{"resources"}, strings.Split(resource, ".")...)
+			value, _, _ := unstructured.NestedFieldNoCopy(container, fields...)
+			if value == nil {
+				return ""
+			}
+			return fmt.Sprintf("%v", value)
+		}
+	}
+	return ""
+}
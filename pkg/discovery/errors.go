@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Errors returned by Discoverer methods wrap one of these. Test for them with errors.Is.
+var (
+	// The kind is neither built in nor defined by a CRD.
+	ErrUnknownKind = errors.New("unknown kind")
+	// The object, or the resource type of a served kind, does not exist.
+	ErrNotFound = errors.New("not found")
+	// The API server refused the request for the current credentials.
+	ErrForbidden = errors.New("forbidden")
+	// A relationship annotation on a CRD could not be parsed.
+	ErrInvalidRelationship = errors.New("invalid relationship")
+)
+
+// Error pairs one of the sentinel errors with a message for the user.
+type Error struct {
+	Err     error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func newError(sentinel error, format string, args ...interface{}) error {
+	return &Error{Err: sentinel, Message: fmt.Sprintf(format, args...)}
+}
+
+// RelationshipError says where a relationship declaration fails to parse.
+// It wraps ErrInvalidRelationship.
+type RelationshipError struct {
+	Declaration string
+	// Byte offset in Declaration
+	Pos    int
+	Reason string
+}
+
+func (e *RelationshipError) Error() string {
+	return fmt.Sprintf("Invalid relationship: %s: column %d: %s", e.Declaration, e.Pos+1, e.Reason)
+}
+
+func (e *RelationshipError) Unwrap() error {
+	return ErrInvalidRelationship
+}
+
+// Maps errors from the API server (or from manifests) onto the sentinel errors.
+func fromAPIError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case apierrors.IsNotFound(err):
+		return &Error{Err: ErrNotFound, Message: err.Error()}
+	case apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return &Error{Err: ErrForbidden, Message: err.Error()}
+	}
+	return err
+}
+
+// Records the first error that leaves the result of a query incomplete and
+// reports whether the caller should stop. Missing objects and kinds that are
+// not installed are expected while following relationships and are skipped.
+func (q *query) fail(err error) bool {
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnknownKind) {
+		return false
+	}
+	if q.err == nil {
+		q.err = err
+	}
+	return true
+}
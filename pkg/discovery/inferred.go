@@ -0,0 +1,270 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// A field shaped like a reference to another object: an ObjectReference
+// ({kind, name} with an optional apiVersion, apiGroup or group and
+// namespace), or a LocalObjectReference ({name}) in a field named like
+// credentialsSecretRef, writeConnectionSecretToRef or configMapRef.
+type inferredReference struct {
+	// e.g. spec.resourceRefs[0]
+	Path string
+	Kind string
+	Group string
+	Name string
+	Namespace string
+}
+
+// An object referring to Target through an inferred reference
+type inferredReferrer struct {
+	Kind string
+	Name string
+	Namespace string
+	Detail string
+	Target ObjectRef
+}
+
+// Every field of content shaped like a reference, outside of metadata whose
+// ownerReferences are followed as owner references.
+func findInferredReferences(content map[string]interface{}) []inferredReference {
+	refs := make([]inferredReference, 0)
+	for field, value := range content {
+		if field == "metadata" {
+			continue
+		}
+		refs = append(refs, walkInferredReferences(value, field, field)...)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Path < refs[j].Path
+	})
+	return refs
+}
+
+func walkInferredReferences(value interface{}, field, at string) []inferredReference {
+	refs := make([]inferredReference, 0)
+	if list, ok := value.([]interface{}); ok {
+		for i, item := range list {
+			refs = append(refs, walkInferredReferences(item, field, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return refs
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return refs
+	}
+	if ref, ok := objectReference(object, field); ok {
+		ref.Path = at
+		refs = append(refs, ref)
+	}
+	for name, child := range object {
+		refs = append(refs, walkInferredReferences(child, name, at+"."+name)...)
+	}
+	return refs
+}
+
+// The reference object holds, if it is shaped like one. field is the name
+// of the field holding it, or of the list holding it.
+func objectReference(object map[string]interface{}, field string) (inferredReference, bool) {
+	ref := inferredReference{}
+	ref.Name, _ = object["name"].(string)
+	if ref.Name == "" {
+		return ref, false
+	}
+	ref.Namespace, _ = object["namespace"].(string)
+	if kind, _ := object["kind"].(string); kind != "" {
+		ref.Kind = kind
+		if apiVersion, ok := object["apiVersion"].(string); ok {
+			if gv, err := schema.ParseGroupVersion(apiVersion); err == nil {
+				ref.Group = gv.Group
+			}
+		}
+		for _, groupField := range []string{"apiGroup", "group"} {
+			if group, _ := object[groupField].(string); group != "" {
+				ref.Group = group
+			}
+		}
+		return ref, true
+	}
+	field = strings.TrimSuffix(strings.ToLower(field), "s")
+	switch {
+	case !strings.HasSuffix(field, "ref"):
+		return ref, false
+	case strings.Contains(field, "secret"):
+		ref.Kind = SECRET
+	case strings.Contains(field, "configmap"):
+		ref.Kind = CONFIG_MAP
+	default:
+		return ref, false
+	}
+	return ref, true
+}
+
+// Connections through references of the object that no relationship
+// declares, to the objects it refers to and from the objects referring to
+// it. Only followed with Options.InferReferences.
+func (q *query) findInferredRelatives(visited []Connection, level int, kind, instance, namespace string) []Connection {
+	obj, err := q.getKubeObject(kind, instance, namespace, q.kindResource(kind))
+	if err != nil {
+		return visited
+	}
+	objNamespace := q.objectNamespace(kind, &obj, namespace)
+	self := ObjectRef{Kind: kind, Name: instance, Namespace: objNamespace}
+	relatives := make(map[string][]Connection)
+	relativeKinds := make([]string, 0)
+	add := func(conn Connection) {
+		if _, ok := relatives[conn.Kind]; !ok {
+			relativeKinds = append(relativeKinds, conn.Kind)
+		}
+		relatives[conn.Kind] = append(relatives[conn.Kind], conn)
+	}
+	for _, ref := range findInferredReferences(obj.UnstructuredContent()) {
+		target, ok := q.inferredTarget(kind, objNamespace, ref)
+		if !ok || target == self {
+			continue
+		}
+		add(Connection{
+			Level: level,
+			Kind: target.Kind,
+			Name: target.Name,
+			Namespace: target.Namespace,
+			RelationType: relTypeSpecProperty,
+			RelationDetails: inferredDetail(ref),
+			Inferred: true,
+			Peer: &Connection{
+				Kind: kind,
+				Name: instance,
+				Namespace: objNamespace,
+			},
+		})
+	}
+	for _, referrer := range q.inferredReferrers(namespace) {
+		if referrer.Target != self || (referrer.Kind == kind && referrer.Name == instance && referrer.Namespace == objNamespace) {
+			continue
+		}
+		add(Connection{
+			Level: level,
+			Kind: referrer.Kind,
+			Name: referrer.Name,
+			Namespace: referrer.Namespace,
+			RelationType: relTypeSpecProperty,
+			RelationDetails: referrer.Detail,
+			Inferred: true,
+			Peer: &Connection{
+				Kind: kind,
+				Name: instance,
+				Namespace: objNamespace,
+			},
+		})
+	}
+	for _, relativeKind := range relativeKinds {
+		conns := relatives[relativeKind]
+		visited = q.buildGraph(visited, level, kind, instance, conns, relativeKind, namespace, relTypeSpecProperty, conns[0].RelationDetails)
+	}
+	return visited
+}
+
+// Objects of the query namespaces, and cluster-scoped objects, with the
+// objects their inferred references refer to. Read once per namespace.
+func (q *query) inferredReferrers(namespace string) []inferredReferrer {
+	if referrers, ok := q.referrers[namespace]; ok {
+		return referrers
+	}
+	kinds := make([]string, 0)
+	for gk := range q.registry.pluralMap {
+		kinds = append(kinds, q.registry.kindName(gk))
+	}
+	sort.Strings(kinds)
+	referrers := make([]inferredReferrer, 0)
+	for _, kind := range kinds {
+		// Kinds that are not served are skipped
+		objects, err := q.getObjects(kind, "*", q.queryNamespaces(namespace), q.kindResource(kind))
+		if err != nil {
+			continue
+		}
+		for _, obj := range objects {
+			objNamespace := q.objectNamespace(kind, obj, namespace)
+			for _, ref := range findInferredReferences(obj.UnstructuredContent()) {
+				target, ok := q.inferredTarget(kind, objNamespace, ref)
+				if !ok {
+					continue
+				}
+				referrers = append(referrers, inferredReferrer{Kind: kind, Name: obj.GetName(), Namespace: objNamespace,
+					Detail: inferredDetail(ref), Target: target})
+			}
+		}
+	}
+	q.referrers[namespace] = referrers
+	return referrers
+}
+
+// The existing object ref, found on an object of kind in namespace, refers
+// to. References that a specproperty relationship of kind already follows
+// are left to it, as are the env and envFrom references of containers when
+// kind has an env relationship.
+func (q *query) inferredTarget(kind, namespace string, ref inferredReference) (ObjectRef, bool) {
+	kindName := ref.Kind
+	if ref.Group != "" {
+		kindName = ref.Kind + "." + ref.Group
+	}
+	gk, err := q.registry.resolveKind(kindName)
+	// Kinds are given by their Kind, not a plural or short name
+	if err != nil || gk.Kind != ref.Kind {
+		return ObjectRef{}, false
+	}
+	target := ObjectRef{Kind: q.registry.kindName(gk), Name: ref.Name}
+	if !q.registry.clusterScoped(target.Kind) {
+		target.Namespace = namespace
+		if ref.Namespace != "" {
+			target.Namespace = ref.Namespace
+		}
+	}
+	namePath := fieldPathWithoutIndexes(ref.Path + ".name")
+	for _, relString := range q.registry.relationshipMap[q.registry.groupKind(kind)] {
+		relType, lhs, _, targetKinds, err := q.registry.parseRelationship(kind, relString)
+		if err != nil || relType != relTypeSpecProperty || !containsString(targetKinds, target.Kind) {
+			continue
+		}
+		if fieldPathWithoutIndexes(lhs) == namePath || (lastField(lhs) == "env" && envReferencePath(namePath)) {
+			return ObjectRef{}, false
+		}
+	}
+	_, err = q.getKubeObject(target.Kind, target.Name, target.Namespace, q.kindResource(target.Kind))
+	if err != nil {
+		return ObjectRef{}, false
+	}
+	return target, true
+}
+
+// e.g. spec.resourceRefs.name for INSTANCE.spec.resourceRefs[*].name
+func fieldPathWithoutIndexes(path string) string {
+	fields := splitFieldPath(path)
+	for i, field := range fields {
+		if open := strings.Index(field, "["); open >= 0 {
+			fields[i] = field[:open]
+		}
+	}
+	return strings.Join(fields, ".")
+}
+
+func envReferencePath(path string) bool {
+	for _, field := range splitFieldPath(path) {
+		if field == "env" || field == "envFrom" {
+			return true
+		}
+	}
+	return false
+}
+
+func inferredDetail(ref inferredReference) string {
+	detail := "Name:" + ref.Path + ".name" + " " + "Value:" + ref.Name
+	if ref.Namespace != "" {
+		detail = detail + " " + "Namespace:" + ref.Namespace
+	}
+	return detail
+}
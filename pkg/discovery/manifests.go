@@ -0,0 +1,268 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// Manifests serves the read-only part of dynamic.Interface from a fixed set
+// of objects loaded from YAML/JSON manifests.
+type Manifests struct {
+	objects []unstructured.Unstructured
+	// Kind to resource name, for built-in kinds and the kinds of the CRDs
+	// found in the manifests.
+	pluralMap map[schema.GroupKind]string
+}
+
+type manifestResource struct {
+	client    *Manifests
+	resource  schema.GroupVersionResource
+	namespace string
+}
+
+// LoadManifests reads objects and CRDs from all .yaml, .yml and .json files
+// under fromDir and from fromFile ("-" reads from stdin). Both single objects
+// and List documents such as the output of 'kubectl get -A -o yaml' are
+// accepted.
+func LoadManifests(fromDir, fromFile string) (*Manifests, error) {
+	objects := make([]unstructured.Unstructured, 0)
+	if fromDir != "" {
+		err := filepath.Walk(fromDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".yaml", ".yml", ".json":
+			default:
+				return nil
+			}
+			fileObjects, err := readManifestFile(path)
+			if err != nil {
+				return err
+			}
+			objects = append(objects, fileObjects...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if fromFile != "" {
+		fileObjects, err := readManifestFile(fromFile)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, fileObjects...)
+	}
+	return newManifests(objects)
+}
+
+func newManifests(objects []unstructured.Unstructured) (*Manifests, error) {
+	m := &Manifests{objects: objects, pluralMap: copyKindMap(KindPluralMap)}
+	crdList, err := m.crds()
+	if err != nil {
+		return nil, err
+	}
+	namespaced := make(map[schema.GroupKind]bool)
+	for gk := range KindPluralMap {
+		namespaced[gk] = !clusterScopedKinds[gk]
+	}
+	for _, crd := range crdList {
+		gk := schema.GroupKind{Group: crd.Spec.Group, Kind: crd.Spec.Names.Kind}
+		m.pluralMap[gk] = crd.Spec.Names.Plural
+		namespaced[gk] = crd.Spec.Scope == apiextensionsv1.NamespaceScoped
+	}
+	// Namespaced manifests without a namespace end up in the default
+	// namespace, as with kubectl apply. Objects of unknown kinds are left as
+	// they are.
+	for i := range m.objects {
+		obj := &m.objects[i]
+		if obj.GetNamespace() == "" && namespaced[obj.GroupVersionKind().GroupKind()] {
+			obj.SetNamespace(metav1.NamespaceDefault)
+		}
+	}
+	return m, nil
+}
+
+func readManifestFile(path string) ([]unstructured.Unstructured, error) {
+	if path == "-" {
+		return readManifests(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	objects, err := readManifests(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return objects, nil
+}
+
+func readManifests(r io.Reader) ([]unstructured.Unstructured, error) {
+	objects := make([]unstructured.Unstructured, 0)
+	decoder := yamlutil.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		// Decoded again so that numbers are int64 like in objects from the API server
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		content := make(map[string]interface{})
+		if len(raw) > 0 && string(raw) != "null" {
+			if err := utiljson.Unmarshal(raw, &content); err != nil {
+				return nil, err
+			}
+		}
+		if len(content) == 0 {
+			continue
+		}
+		obj := unstructured.Unstructured{Object: content}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, list.Items...)
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// Resource name of an object as seen by the dynamic client.
+func (c *Manifests) resourceName(obj unstructured.Unstructured) string {
+	gk := obj.GroupVersionKind().GroupKind()
+	if plural, ok := c.pluralMap[gk]; ok && plural != "" {
+		return plural
+	}
+	plural, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+	return plural.Resource
+}
+
+// The version is not compared so that manifests written against an older
+// or newer API version still match.
+func (c *Manifests) matches(obj unstructured.Unstructured, res schema.GroupVersionResource, namespace string) bool {
+	if obj.GroupVersionKind().Group != res.Group || c.resourceName(obj) != res.Resource {
+		return false
+	}
+	// Objects without a namespace are cluster-scoped, or of kinds whose
+	// scope is not known; either way they are visible from every namespace.
+	if namespace != "" && obj.GetNamespace() != "" && obj.GetNamespace() != namespace {
+		return false
+	}
+	return true
+}
+
+func (c *Manifests) crds() ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	crdList := make([]*apiextensionsv1.CustomResourceDefinition, 0)
+	for _, obj := range c.objects {
+		if obj.GetKind() != "CustomResourceDefinition" || obj.GroupVersionKind().Group != "apiextensions.k8s.io" {
+			continue
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), crd)
+		if err != nil {
+			return nil, fmt.Errorf("CRD %s: %s", obj.GetName(), err.Error())
+		}
+		// apiextensions.k8s.io/v1beta1 CRDs may only carry spec.version
+		if len(crd.Spec.Versions) == 0 {
+			version, _, _ := unstructured.NestedString(obj.UnstructuredContent(), "spec", "version")
+			if version == "" {
+				continue
+			}
+			crd.Spec.Versions = []apiextensionsv1.CustomResourceDefinitionVersion{{Name: version, Served: true, Storage: true}}
+		}
+		crdList = append(crdList, crd)
+	}
+	return crdList, nil
+}
+
+func (c *Manifests) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &manifestResource{client: c, resource: resource}
+}
+
+func (r *manifestResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return &manifestResource{client: r.client, resource: r.resource, namespace: namespace}
+}
+
+func (r *manifestResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	for _, obj := range r.client.objects {
+		if r.client.matches(obj, r.resource, r.namespace) {
+			list.Items = append(list.Items, *obj.DeepCopy())
+		}
+	}
+	return list, nil
+}
+
+func (r *manifestResource) Get(ctx context.Context, name string, options metav1.GetOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	for _, obj := range r.client.objects {
+		if obj.GetName() == name && r.client.matches(obj, r.resource, r.namespace) {
+			return obj.DeepCopy(), nil
+		}
+	}
+	return nil, apierrors.NewNotFound(r.resource.GroupResource(), name)
+}
+
+func (r *manifestResource) readOnly(action string) error {
+	return apierrors.NewMethodNotSupported(r.resource.GroupResource(), action)
+}
+
+func (r *manifestResource) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return nil, r.readOnly("create")
+}
+
+func (r *manifestResource) Update(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return nil, r.readOnly("update")
+}
+
+func (r *manifestResource) UpdateStatus(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions) (*unstructured.Unstructured, error) {
+	return nil, r.readOnly("update")
+}
+
+func (r *manifestResource) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	return r.readOnly("delete")
+}
+
+func (r *manifestResource) DeleteCollection(ctx context.Context, options metav1.DeleteOptions, listOptions metav1.ListOptions) error {
+	return r.readOnly("deletecollection")
+}
+
+func (r *manifestResource) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	return nil, r.readOnly("watch")
+}
+
+func (r *manifestResource) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return nil, r.readOnly("patch")
+}
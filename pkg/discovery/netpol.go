@@ -0,0 +1,271 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Reachability is whether a Pod may send traffic to another Pod according to
+// the NetworkPolicies that apply to them.
+type Reachability struct {
+	Allowed bool
+	// Decided by the NetworkPolicies of the source Pod
+	Egress PolicyDecision
+	// Decided by the NetworkPolicies of the destination Pod
+	Ingress PolicyDecision
+}
+
+// PolicyDecision is what the NetworkPolicies of one of the Pods decide.
+type PolicyDecision struct {
+	Allowed bool
+	// NetworkPolicies that select the Pod for the direction, as namespace/name.
+	// When there are none the Pod is not isolated and traffic is allowed.
+	Policies []string
+	// The rule that allows the traffic, e.g. default/allow-web ingress[0]
+	Rule string
+	Reason string
+}
+
+// Reachability evaluates the NetworkPolicies for traffic from the Pod from to
+// the Pod to on port and protocol (TCP when empty). A port of 0 stands for any
+// port, rules limited to some ports then allow the traffic.
+func (d *Discoverer) Reachability(ctx context.Context, from, to ObjectRef, port int, protocol string) (Reachability, error) {
+	q := d.newQuery(ctx)
+	reachability := Reachability{}
+	if protocol == "" {
+		protocol = "TCP"
+	}
+	pods := make([]*unstructured.Unstructured, 0)
+	for _, ref := range []ObjectRef{from, to} {
+		ref.Kind = POD
+		ref, err := q.resolveRef(ref)
+		if err != nil {
+			return reachability, err
+		}
+		err = q.checkExistence(ref.Kind, ref.Name, ref.Namespace)
+		if err != nil {
+			return reachability, err
+		}
+		pod, err := q.getKubeObject(POD, ref.Name, ref.Namespace, q.kindResource(POD))
+		if err != nil {
+			return reachability, err
+		}
+		if pod.GetNamespace() == "" {
+			pod.SetNamespace(ref.Namespace)
+		}
+		pods = append(pods, &pod)
+	}
+	var err error
+	reachability.Egress, err = q.policyDecision("Egress", pods[0], pods[1], pods[1], port, protocol)
+	if err != nil {
+		return reachability, err
+	}
+	reachability.Ingress, err = q.policyDecision("Ingress", pods[1], pods[0], pods[1], port, protocol)
+	if err != nil {
+		return reachability, err
+	}
+	reachability.Allowed = reachability.Egress.Allowed && reachability.Ingress.Allowed
+	return reachability, nil
+}
+
+// What the NetworkPolicies selecting pod for direction (Ingress or Egress)
+// decide for traffic with peer. Named ports are those of destination.
+func (q *query) policyDecision(direction string, pod, peer, destination *unstructured.Unstructured, port int, protocol string) (PolicyDecision, error) {
+	decision := PolicyDecision{Policies: []string{}}
+	policies, err := q.getObjects(NETWORK_POLICY, "*", []string{pod.GetNamespace()}, q.kindResource(NETWORK_POLICY))
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUnknownKind) {
+		return decision, err
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].GetName() < policies[j].GetName()
+	})
+	rulesField, peersField := "ingress", "from"
+	if direction == "Egress" {
+		rulesField, peersField = "egress", "to"
+	}
+	traffic := protocol + "/any port"
+	if port != 0 {
+		traffic = fmt.Sprintf("%s/%d", protocol, port)
+	}
+	for _, policy := range policies {
+		content := policy.UnstructuredContent()
+		podSelectorMap, _, _ := unstructured.NestedMap(content, "spec", "podSelector")
+		if !selectorMatches(selectorFromMap(podSelectorMap), pod.GetLabels()) || !policyHasType(policy, direction) {
+			continue
+		}
+		policyName := policy.GetNamespace() + "/" + policy.GetName()
+		if policy.GetNamespace() == "" {
+			policyName = pod.GetNamespace() + "/" + policy.GetName()
+		}
+		decision.Policies = append(decision.Policies, policyName)
+		if decision.Allowed {
+			continue
+		}
+		rules, _, _ := unstructured.NestedSlice(content, "spec", rulesField)
+		for i, item := range rules {
+			rule, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			peers, _, _ := unstructured.NestedSlice(rule, peersField)
+			ports, _, _ := unstructured.NestedSlice(rule, "ports")
+			if q.peersMatch(peers, pod.GetNamespace(), peer) && portsMatch(ports, port, protocol, destination) {
+				decision.Allowed = true
+				decision.Rule = fmt.Sprintf("%s %s[%d]", policyName, rulesField, i)
+				decision.Reason = "allowed by " + decision.Rule
+				break
+			}
+		}
+	}
+	podName := "Pod " + pod.GetNamespace() + "/" + pod.GetName()
+	peerName := "Pod " + peer.GetNamespace() + "/" + peer.GetName()
+	if len(decision.Policies) == 0 {
+		decision.Allowed = true
+		decision.Reason = "no NetworkPolicy selects " + podName + " for " + rulesField
+	} else if !decision.Allowed {
+		peerDirection := "from"
+		if direction == "Egress" {
+			peerDirection = "to"
+		}
+		decision.Reason = "NetworkPolicies " + strings.Join(decision.Policies, ", ") + " select " + podName + " for " + rulesField +
+			" and no rule allows traffic " + peerDirection + " " + peerName + " on " + traffic
+	}
+	return decision, nil
+}
+
+// Policies without policyTypes are Ingress policies, and Egress policies
+// when they have egress rules
+func policyHasType(policy *unstructured.Unstructured, direction string) bool {
+	policyTypes, found, _ := unstructured.NestedStringSlice(policy.UnstructuredContent(), "spec", "policyTypes")
+	if !found {
+		_, hasEgress, _ := unstructured.NestedFieldNoCopy(policy.UnstructuredContent(), "spec", "egress")
+		return direction == "Ingress" || hasEgress
+	}
+	return containsString(policyTypes, direction)
+}
+
+// Rules without peers allow all peers
+func (q *query) peersMatch(peers []interface{}, policyNamespace string, pod *unstructured.Unstructured) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, item := range peers {
+		peer, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if ipBlock, ok := peer["ipBlock"].(map[string]interface{}); ok {
+			if ipBlockMatches(ipBlock, pod) {
+				return true
+			}
+			continue
+		}
+		podSelectorMap, hasPodSelector := peer["podSelector"].(map[string]interface{})
+		namespaceSelectorMap, hasNamespaceSelector := peer["namespaceSelector"].(map[string]interface{})
+		if !hasPodSelector && !hasNamespaceSelector {
+			continue
+		}
+		if hasNamespaceSelector {
+			if !selectorMatches(selectorFromMap(namespaceSelectorMap), q.namespaceLabels(pod.GetNamespace())) {
+				continue
+			}
+		} else if pod.GetNamespace() != policyNamespace {
+			continue
+		}
+		if hasPodSelector && !selectorMatches(selectorFromMap(podSelectorMap), pod.GetLabels()) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Whether the IP of pod is in the ipBlock. Pods that have no IP yet are not.
+func ipBlockMatches(ipBlock map[string]interface{}, pod *unstructured.Unstructured) bool {
+	podIP, _, _ := unstructured.NestedString(pod.UnstructuredContent(), "status", "podIP")
+	ip := net.ParseIP(podIP)
+	cidr, _, _ := unstructured.NestedString(ipBlock, "cidr")
+	_, block, err := net.ParseCIDR(cidr)
+	if ip == nil || err != nil || !block.Contains(ip) {
+		return false
+	}
+	except, _, _ := unstructured.NestedStringSlice(ipBlock, "except")
+	for _, exceptCIDR := range except {
+		if _, exceptBlock, err := net.ParseCIDR(exceptCIDR); err == nil && exceptBlock.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rules without ports allow all ports. Ports are numbers, ranges up to
+// endPort, or names of container ports of destination.
+func portsMatch(ports []interface{}, port int, protocol string, destination *unstructured.Unstructured) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, item := range ports {
+		policyPort, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		portProtocol, _, _ := unstructured.NestedString(policyPort, "protocol")
+		if portProtocol == "" {
+			portProtocol = "TCP"
+		}
+		if portProtocol != protocol {
+			continue
+		}
+		value, hasPort := policyPort["port"]
+		if !hasPort || port == 0 {
+			return true
+		}
+		switch p := value.(type) {
+		case int64:
+			endPort, hasEndPort, _ := unstructured.NestedInt64(policyPort, "endPort")
+			if int64(port) == p || (hasEndPort && int64(port) >= p && int64(port) <= endPort) {
+				return true
+			}
+		case string:
+			if containerPortNumber(destination, p, protocol) == port {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Number of the container port of pod with name, 0 if there is none
+func containerPortNumber(pod *unstructured.Unstructured, name, protocol string) int {
+	for _, containerType := range containerTypes {
+		containerList, _, _ := unstructured.NestedSlice(pod.UnstructuredContent(), "spec", containerType)
+		for _, cont := range containerList {
+			container, ok := cont.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			containerPorts, _, _ := unstructured.NestedSlice(container, "ports")
+			for _, item := range containerPorts {
+				containerPort, ok := item.(map[string]interface{})
+				if !ok || containerPort["name"] != name {
+					continue
+				}
+				portProtocol, _, _ := unstructured.NestedString(containerPort, "protocol")
+				if portProtocol == "" {
+					portProtocol = "TCP"
+				}
+				number, _, _ := unstructured.NestedInt64(containerPort, "containerPort")
+				if portProtocol == protocol {
+					return int(number)
+				}
+			}
+		}
+	}
+	return 0
+}
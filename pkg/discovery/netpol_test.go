@@ -0,0 +1,209 @@
+package discovery
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const netpolManifests = `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: ops
+  labels:
+    team: ops
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  namespace: default
+  labels:
+    app: web
+spec:
+  containers:
+  - name: web
+    image: nginx
+    ports:
+    - name: http
+      containerPort: 8080
+    - name: dns
+      containerPort: 53
+      protocol: UDP
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: client
+  namespace: default
+  labels:
+    app: client
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: monitor
+  namespace: ops
+  labels:
+    app: prometheus
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-web
+  namespace: default
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  ingress:
+  - from:
+    - podSelector:
+        matchLabels:
+          app: client
+    ports:
+    - port: http
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: allow-metrics
+  namespace: default
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  ingress:
+  - from:
+    - namespaceSelector:
+        matchLabels:
+          team: ops
+    ports:
+    - port: 9000
+      endPort: 9100
+---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-egress
+  namespace: default
+spec:
+  podSelector:
+    matchLabels:
+      app: client
+  policyTypes: [Egress]
+`
+
+func netpolTestQuery(t *testing.T) (*query, map[string]*unstructured.Unstructured) {
+	objects, err := readManifests(strings.NewReader(netpolManifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := newManifests(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDiscoverer(nil, Options{Manifests: manifests})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pods := make(map[string]*unstructured.Unstructured)
+	for i := range objects {
+		if objects[i].GetKind() == POD {
+			pods[objects[i].GetName()] = &objects[i]
+		}
+	}
+	return d.newQuery(context.Background()), pods
+}
+
+func TestPolicyDecision(t *testing.T) {
+	q, pods := netpolTestQuery(t)
+	tests := []struct {
+		direction string
+		pod       string
+		peer      string
+		port      int
+		protocol  string
+		allowed   bool
+		policies  string
+		// Start of the reason
+		reason string
+	}{
+		{"Ingress", "web", "client", 8080, "TCP", true, "default/allow-metrics,default/allow-web", "allowed by default/allow-web ingress[0]"},
+		{"Ingress", "web", "client", 0, "TCP", true, "default/allow-metrics,default/allow-web", "allowed by default/allow-web ingress[0]"},
+		{"Ingress", "web", "client", 8080, "UDP", false, "default/allow-metrics,default/allow-web",
+			"NetworkPolicies default/allow-metrics, default/allow-web select Pod default/web for ingress and no rule allows traffic from Pod default/client on UDP/8080"},
+		{"Ingress", "web", "client", 9000, "TCP", false, "default/allow-metrics,default/allow-web", "NetworkPolicies"},
+		{"Ingress", "web", "monitor", 9050, "TCP", true, "default/allow-metrics,default/allow-web", "allowed by default/allow-metrics ingress[0]"},
+		{"Ingress", "web", "monitor", 9101, "TCP", false, "default/allow-metrics,default/allow-web", "NetworkPolicies"},
+		{"Ingress", "web", "monitor", 8080, "TCP", false, "default/allow-metrics,default/allow-web", "NetworkPolicies"},
+		{"Egress", "client", "web", 8080, "TCP", false, "default/deny-egress",
+			"NetworkPolicies default/deny-egress select Pod default/client for egress and no rule allows traffic to Pod default/web on TCP/8080"},
+		{"Egress", "web", "client", 0, "TCP", true, "", "no NetworkPolicy selects Pod default/web for egress"},
+		{"Ingress", "client", "web", 0, "TCP", true, "", "no NetworkPolicy selects Pod default/client for ingress"},
+	}
+	for _, test := range tests {
+		destination := pods[test.pod]
+		if test.direction == "Egress" {
+			destination = pods[test.peer]
+		}
+		decision, err := q.policyDecision(test.direction, pods[test.pod], pods[test.peer], destination, test.port, test.protocol)
+		name := test.direction + " " + test.pod + "/" + test.peer
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if decision.Allowed != test.allowed || strings.Join(decision.Policies, ",") != test.policies {
+			t.Errorf("%s on %d: got allowed %v by %v, want %v by %s", name, test.port, decision.Allowed, decision.Policies,
+				test.allowed, test.policies)
+		}
+		if !strings.HasPrefix(decision.Reason, test.reason) {
+			t.Errorf("%s on %d: got reason %q, want %q", name, test.port, decision.Reason, test.reason)
+		}
+	}
+}
+
+func TestPortsMatch(t *testing.T) {
+	_, pods := netpolTestQuery(t)
+	port := func(fields ...interface{}) map[string]interface{} {
+		policyPort := make(map[string]interface{})
+		for i := 0; i < len(fields); i += 2 {
+			policyPort[fields[i].(string)] = fields[i+1]
+		}
+		return policyPort
+	}
+	tests := []struct {
+		name     string
+		ports    []interface{}
+		port     int
+		protocol string
+		want     bool
+	}{
+		{"no ports", nil, 80, "TCP", true},
+		{"number", []interface{}{port("port", int64(80))}, 80, "TCP", true},
+		{"other number", []interface{}{port("port", int64(80))}, 81, "TCP", false},
+		{"any port", []interface{}{port("port", int64(80))}, 0, "TCP", true},
+		{"protocol only", []interface{}{port("protocol", "TCP")}, 1234, "TCP", true},
+		{"other protocol", []interface{}{port("port", int64(80), "protocol", "UDP")}, 80, "TCP", false},
+		{"protocol", []interface{}{port("port", int64(80), "protocol", "UDP")}, 80, "UDP", true},
+		{"range start", []interface{}{port("port", int64(8000), "endPort", int64(8100))}, 8000, "TCP", true},
+		{"in range", []interface{}{port("port", int64(8000), "endPort", int64(8100))}, 8050, "TCP", true},
+		{"range end", []interface{}{port("port", int64(8000), "endPort", int64(8100))}, 8100, "TCP", true},
+		{"after range", []interface{}{port("port", int64(8000), "endPort", int64(8100))}, 8101, "TCP", false},
+		{"before range", []interface{}{port("port", int64(8000), "endPort", int64(8100))}, 7999, "TCP", false},
+		{"named", []interface{}{port("port", "http")}, 8080, "TCP", true},
+		{"named other number", []interface{}{port("port", "http")}, 8081, "TCP", false},
+		{"named unknown", []interface{}{port("port", "grpc")}, 8080, "TCP", false},
+		{"named UDP", []interface{}{port("port", "dns", "protocol", "UDP")}, 53, "UDP", true},
+		{"named other protocol", []interface{}{port("port", "dns")}, 53, "TCP", false},
+		{"second port", []interface{}{port("port", int64(80)), port("port", "http")}, 8080, "TCP", true},
+	}
+	for _, test := range tests {
+		if got := portsMatch(test.ports, test.port, test.protocol, pods["web"]); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"strings"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/coreos/etcd/client"
 	"k8s.io/client-go/kubernetes"
@@ -72,30 +73,29 @@ func getCRDDetails(crdDetailsString string) (string, string, string, []string, s
 	return kind, plural, endpoint, composition, implementationChoicesCMapName, usageCMapName, openapiSpecCMapName
 }
 
-func GetUsageDetails(customResourceKind string, namespace string) (string) {
-	var manPage, usageDetailsData, relationships string
-	crdClient, err1 := apiextensionsclientset.NewForConfig(cfg)
-	if err1 != nil {
-		fmt.Errorf("Error:%s\n", err1)
-		return manPage
-	}
-	crdList, err := crdClient.CustomResourceDefinitions().List(context.TODO(),
-		metav1.ListOptions{})
+func (d *Discoverer) GetUsageDetails(ctx context.Context, customResourceKind string, namespace string) (string, error) {
+	var usageDetailsData, relationships string
+	crdList, err := d.listCRDs(ctx)
 	if err != nil {
-		fmt.Errorf("Error:%s\n", err)
-		return manPage
+		return "", err
 	}
-	for _, crd := range crdList.Items {
-		crdName := crd.ObjectMeta.Name
-		crdObj, err := crdClient.CustomResourceDefinitions().Get(context.TODO(),
-			crdName, 
-			metav1.GetOptions{})
+	// The kind may be given in any of the forms accepted by connections
+	registry := newKindRegistry(d.mapper)
+	for _, crdObj := range crdList {
+		registry.parseCRDAnnotions(crdObj)
+	}
+	var gk schema.GroupKind
+	if customResourceKind != "" {
+		gk, err = registry.resolveKind(customResourceKind)
 		if err != nil {
-			fmt.Errorf("Error:%s\n", err)
-			return manPage
+			return "", err
 		}
+	}
+	found := false
+	for _, crdObj := range crdList {
 		if customResourceKind != "" {
-			if customResourceKind == crdObj.Spec.Names.Kind {
+			if gk.Kind == crdObj.Spec.Names.Kind && gk.Group == crdObj.Spec.Group {
+				found = true
 				//fmt.Printf("%v\n", crdObj)
 				objectMeta := crdObj.ObjectMeta
 				annotations := objectMeta.GetAnnotations()
@@ -104,10 +104,9 @@ func GetUsageDetails(customResourceKind string, namespace string) (string) {
 				usageDetailsCMapName := annotations[USAGE_ANNOTATION]
 				//fmt.Printf("usageDetailsCMapName:%s\n", usageDetailsCMapName)
 				if usageDetailsCMapName != "" {
-					usageDetailsData, err = readConfigMap(usageDetailsCMapName, namespace)
+					usageDetailsData, err = d.readConfigMap(ctx, usageDetailsCMapName, namespace)
 					if err != nil {
-						fmt.Printf("Error:%s\n", err.Error())
-						usageDetailsData = "Could not find usage details data."
+						return "", err
 					}
 				}
 				//subresources := annotations[COMPOSITION_ANNOTATION]
@@ -145,10 +144,13 @@ func GetUsageDetails(customResourceKind string, namespace string) (string) {
 	manPage = manPage + "=================\n"
 	manPage = manPage + usageDetailsData + "\n\n"
 */
-	return usageDetailsData
+	if !found {
+		return "", newError(ErrUnknownKind, "No Custom Resource Definition found for kind %s", customResourceKind)
+	}
+	return usageDetailsData, nil
 }
 
-func GetUsageDetails1(customResourceKind string) (string, error) {
+func (d *Discoverer) GetUsageDetails1(ctx context.Context, customResourceKind string) (string, error) {
 	var usageDetailsData string
 	var kind, usageDetailsCMapName string
 	crdNameList, err := queryETCDNodes("/crds")
@@ -166,7 +168,7 @@ func GetUsageDetails1(customResourceKind string) (string, error) {
 		kind, _, _, _, _, usageDetailsCMapName, _ = getCRDDetails(crdDetailsString)
 
 		if kind == customResourceKind {
-			usageDetailsData, err = readConfigMap(usageDetailsCMapName,"default")
+			usageDetailsData, err = d.readConfigMap(ctx, usageDetailsCMapName,"default")
 			if err != nil {
 				fmt.Printf("Error:%s\n", err.Error())
 				usageDetailsData = "Could not find usage details data."
@@ -175,10 +177,10 @@ func GetUsageDetails1(customResourceKind string) (string, error) {
 			return usageDetailsData, err
 		}
 	}
-	return "", err
+	return "", newError(ErrUnknownKind, "No Custom Resource Definition found for kind %s", customResourceKind)
 }
 
-func GetImplementationDetails(customResourceKind string) (string, error) {
+func (d *Discoverer) GetImplementationDetails(ctx context.Context, customResourceKind string) (string, error) {
 	var implementationDetailsData string
 	var kind, implementationDetailsCMapName string
 	crdNameList, err := queryETCDNodes("/crds")
@@ -197,7 +199,7 @@ func GetImplementationDetails(customResourceKind string) (string, error) {
 		fmt.Printf(":::: Implementation Details CMap:%s ::::", implementationDetailsCMapName)
 
 		if kind == customResourceKind {
-			implementationDetailsData, err = readConfigMap(implementationDetailsCMapName, "default")
+			implementationDetailsData, err = d.readConfigMap(ctx, implementationDetailsCMapName, "default")
 			if err != nil {
 				fmt.Printf("Error:%s\n", err.Error())
 				implementationDetailsData = "Could not find implementation details data."
@@ -206,10 +208,10 @@ func GetImplementationDetails(customResourceKind string) (string, error) {
 			return implementationDetailsData, err
 		}
 	}
-	return "", err
+	return "", newError(ErrUnknownKind, "No Custom Resource Definition found for kind %s", customResourceKind)
 }
 
-func GetOpenAPISpec(customResourceKind string) (string, error) {
+func (d *Discoverer) GetOpenAPISpec(ctx context.Context, customResourceKind string) (string, error) {
 
 	var openapiData string
 	var kind, openapispecCMapName string
@@ -228,7 +230,7 @@ func GetOpenAPISpec(customResourceKind string) (string, error) {
 		kind, _, _, _, _, _, openapispecCMapName = getCRDDetails(crdDetailsString)
 
 		if kind == customResourceKind {
-			openapiData, err = readConfigMap(openapispecCMapName, "default")
+			openapiData, err = d.readConfigMap(ctx, openapispecCMapName, "default")
 			if err != nil {
 				fmt.Printf("Error:%s\n", err.Error())
 				openapiData = "Could not find implementation details data."
@@ -237,7 +239,7 @@ func GetOpenAPISpec(customResourceKind string) (string, error) {
 			return openapiData, err
 		}
 	}
-	return "", err
+	return "", newError(ErrUnknownKind, "No Custom Resource Definition found for kind %s", customResourceKind)
 }
 
 func GetOpenAPISpec_prev(customResourceKind string) string {
@@ -276,13 +278,7 @@ func GetOpenAPISpec_prev(customResourceKind string) string {
 	return openAPISpec
 }
 
-func readConfigMap(implementationDetailsString string, namespace string) (string, error) {
-
-	kubeClient, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		fmt.Printf("Error:%s\n", err.Error())
-		return "", err
-	}
+func (d *Discoverer) readConfigMap(ctx context.Context, implementationDetailsString string, namespace string) (string, error) {
 
 	fields := strings.Split(implementationDetailsString, ".")
 
@@ -292,24 +288,25 @@ func readConfigMap(implementationDetailsString string, namespace string) (string
 		namespace = fields[0]
 		configMapName = fields[1]
 		dataFieldName = fields[2]
-	} else {
+	} else if len(fields) == 2 {
 		configMapName = fields[0]
 		dataFieldName = fields[1]
+	} else {
+		return "", fmt.Errorf("expected [<namespace>.]<configmap>.<key>, got %s", implementationDetailsString)
 	}
 
 	//fmt.Printf("Namespace:%s, configMapName:%s, dataFieldName:%s", namespace, configMapName, dataFieldName)
 
-	configMap, err := kubeClient.CoreV1().ConfigMaps(namespace).Get(context.TODO(),
-																	configMapName, 
-																	metav1.GetOptions{})
+	res := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	configMap, err := d.client.Resource(res).Namespace(namespace).Get(ctx,
+																	  configMapName,
+																	  metav1.GetOptions{})
 
 	if err != nil {
-		fmt.Printf("Error:%s\n", err.Error())
-		return "", err
+		return "", fromAPIError(err)
 	}
 
-	configMapData := configMap.Data
-	data := configMapData[dataFieldName]
+	data, _, _ := unstructured.NestedString(configMap.Object, "data", dataFieldName)
 
 	//fmt.Printf("Data:%s", data)
 
@@ -0,0 +1,283 @@
+package discovery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RulePack is a set of RelationshipRules for the CRDs of an operator that do
+// not carry kubediscovery annotations. A pack is enabled by Options.Packs, or
+// when a CRD of one of its groups is installed.
+type RulePack struct {
+	Name string
+	// Bumped whenever the rules of the pack change
+	Version string
+	Groups []string
+	// RelationshipRule manifests
+	rules string
+}
+
+// Options.Packs entry that stops packs from being enabled by their CRDs
+const noAutoPacks = "none"
+
+var rulePacks = []RulePack{
+	{Name: "cert-manager", Version: "1", Groups: []string{"cert-manager.io", "acme.cert-manager.io"}, rules: certManagerRules},
+	{Name: "prometheus-operator", Version: "1", Groups: []string{"monitoring.coreos.com"}, rules: prometheusOperatorRules},
+	{Name: "istio", Version: "1", Groups: []string{"networking.istio.io"}, rules: istioRules},
+	{Name: "argo-cd", Version: "1", Groups: []string{"argoproj.io"}, rules: argoCDRules},
+	{Name: "external-secrets", Version: "1", Groups: []string{"external-secrets.io"}, rules: externalSecretsRules},
+}
+
+// RulePacks returns the packs built into kubediscovery.
+func RulePacks() []RulePack {
+	return append([]RulePack{}, rulePacks...)
+}
+
+// Rules of the packs that Options.Packs names, and of the packs whose groups
+// have CRDs among those read so far.
+func (q *query) packRules() ([]relationshipRule, error) {
+	rules := make([]relationshipRule, 0)
+	enabled := make(map[string]bool)
+	auto := true
+	for _, name := range q.d.options.Packs {
+		name = strings.TrimSpace(name)
+		if name == noAutoPacks {
+			auto = false
+			continue
+		}
+		if _, ok := findRulePack(name); !ok {
+			names := make([]string, 0)
+			for _, pack := range rulePacks {
+				names = append(names, pack.Name)
+			}
+			return rules, fmt.Errorf("unknown pack %s, use one of %s", name, strings.Join(names, ", "))
+		}
+		enabled[name] = true
+	}
+	groups := make(map[string]bool)
+	for gk := range q.registry.crdcompositionMap {
+		groups[gk.Group] = true
+	}
+	for _, pack := range rulePacks {
+		installed := false
+		for _, group := range pack.Groups {
+			installed = installed || groups[group]
+		}
+		if !enabled[pack.Name] && !(auto && installed) {
+			continue
+		}
+		objects, err := readManifests(strings.NewReader(pack.rules))
+		if err != nil {
+			return rules, fmt.Errorf("%s pack: %s", pack.Name, err.Error())
+		}
+		for i := range objects {
+			source := pack.Name + " pack v" + pack.Version + ": " + RELATIONSHIP_RULE + "/" + objects[i].GetName()
+			rules = append(rules, parseRelationshipRule(&objects[i], source))
+		}
+	}
+	return rules, nil
+}
+
+func findRulePack(name string) (RulePack, bool) {
+	for _, pack := range rulePacks {
+		if pack.Name == name {
+			return pack, true
+		}
+	}
+	return RulePack{}, false
+}
+
+// The Secret of a Certificate is only owned by it with
+// --enable-certificate-owner-ref, so it is related by secretName instead.
+// issuerRef names an Issuer or a ClusterIssuer.
+const certManagerRules = `
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: cert-manager-certificate
+spec:
+  kind: Certificate.cert-manager.io
+  composition: [CertificateRequest]
+  relationships:
+  - "specproperty, on:INSTANCE.spec.secretName, value:Secret.metadata.name"
+  - "specproperty, on:INSTANCE.spec.issuerRef.name, value:Issuer.metadata.name"
+  - "specproperty, on:INSTANCE.spec.issuerRef.name, value:ClusterIssuer.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: cert-manager-certificaterequest
+spec:
+  kind: CertificateRequest.cert-manager.io
+  composition: [Order]
+  relationships:
+  - "specproperty, on:INSTANCE.spec.issuerRef.name, value:Issuer.metadata.name"
+  - "specproperty, on:INSTANCE.spec.issuerRef.name, value:ClusterIssuer.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: cert-manager-issuer
+spec:
+  kind: Issuer.cert-manager.io
+  relationships:
+  - "specproperty, on:INSTANCE.spec.ca.secretName, value:Secret.metadata.name"
+  - "specproperty, on:INSTANCE.spec.acme.privateKeySecretRef.name, value:Secret.metadata.name"
+  - "annotation, on:Ingress, key:cert-manager.io/issuer, value:INSTANCE.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: cert-manager-clusterissuer
+spec:
+  kind: ClusterIssuer.cert-manager.io
+  relationships:
+  - "specproperty, on:INSTANCE.spec.ca.secretName, value:Secret.metadata.name"
+  - "specproperty, on:INSTANCE.spec.acme.privateKeySecretRef.name, value:Secret.metadata.name"
+  - "annotation, on:Ingress, key:cert-manager.io/cluster-issuer, value:INSTANCE.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: cert-manager-order
+spec:
+  kind: Order.acme.cert-manager.io
+  composition: [Challenge]
+`
+
+// namespaceSelectors of monitors are not followed; they select in their own
+// namespace.
+const prometheusOperatorRules = `
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: prometheus-operator-prometheus
+spec:
+  kind: Prometheus.monitoring.coreos.com
+  composition: [StatefulSet, Secret, ConfigMap]
+  relationships:
+  - "label, on:ServiceMonitor, value:INSTANCE.spec.serviceMonitorSelector"
+  - "label, on:PodMonitor, value:INSTANCE.spec.podMonitorSelector"
+  - "label, on:PrometheusRule, value:INSTANCE.spec.ruleSelector"
+  - "specproperty, on:INSTANCE.spec.serviceAccountName, value:ServiceAccount.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: prometheus-operator-alertmanager
+spec:
+  kind: Alertmanager.monitoring.coreos.com
+  composition: [StatefulSet, Secret]
+  relationships:
+  - "specproperty, on:INSTANCE.spec.serviceAccountName, value:ServiceAccount.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: prometheus-operator-servicemonitor
+spec:
+  kind: ServiceMonitor.monitoring.coreos.com
+  relationships:
+  - "label, on:Service, value:INSTANCE.spec.selector"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: prometheus-operator-podmonitor
+spec:
+  kind: PodMonitor.monitoring.coreos.com
+  relationships:
+  - "label, on:Pod, value:INSTANCE.spec.selector"
+`
+
+// Hosts are matched as Service names, so FQDNs such as
+// reviews.default.svc.cluster.local are not followed. Gateway is the Istio
+// Gateway, the Kind of the group of the declaring kind.
+const istioRules = `
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: istio-virtualservice
+spec:
+  kind: VirtualService.networking.istio.io
+  relationships:
+  - "specproperty, on:INSTANCE.spec.http.route.destination.host, value:Service.metadata.name"
+  - "specproperty, on:INSTANCE.spec.tcp.route.destination.host, value:Service.metadata.name"
+  - "specproperty, on:INSTANCE.spec.tls.route.destination.host, value:Service.metadata.name"
+  - "specproperty, on:INSTANCE.spec.gateways, value:Gateway.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: istio-destinationrule
+spec:
+  kind: DestinationRule.networking.istio.io
+  relationships:
+  - "specproperty, on:INSTANCE.spec.host, value:Service.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: istio-gateway
+spec:
+  kind: Gateway.networking.istio.io
+  relationships:
+  - "label, on:Pod, value:INSTANCE.spec.selector"
+  - "specproperty, on:INSTANCE.spec.servers.tls.credentialName, value:Secret.metadata.name"
+`
+
+// Applications do not own what they deploy
+const argoCDRules = `
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: argo-cd-application
+spec:
+  kind: Application.argoproj.io
+  relationships:
+  - "specproperty, on:INSTANCE.spec.project, value:AppProject.metadata.name"
+  - "specproperty, on:INSTANCE.spec.destination.namespace, value:Namespace.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: argo-cd-applicationset
+spec:
+  kind: ApplicationSet.argoproj.io
+  composition: [Application]
+`
+
+// The target Secret is named after the ExternalSecret unless
+// spec.target.name is set; it is owned by it with the default
+// creationPolicy.
+const externalSecretsRules = `
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: external-secrets-externalsecret
+spec:
+  kind: ExternalSecret.external-secrets.io
+  composition: [Secret]
+  relationships:
+  - "specproperty, on:INSTANCE.spec.target.name, value:Secret.metadata.name"
+  - "specproperty, on:INSTANCE.spec.secretStoreRef.name, value:SecretStore.metadata.name"
+  - "specproperty, on:INSTANCE.spec.secretStoreRef.name, value:ClusterSecretStore.metadata.name"
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: external-secrets-clusterexternalsecret
+spec:
+  kind: ClusterExternalSecret.external-secrets.io
+  composition: [ExternalSecret]
+---
+apiVersion: kubediscovery.cloudark.io/v1
+kind: RelationshipRule
+metadata:
+  name: external-secrets-pushsecret
+spec:
+  kind: PushSecret.external-secrets.io
+  relationships:
+  - "specproperty, on:INSTANCE.spec.selector.secret.name, value:Secret.metadata.name"
+  - "specproperty, on:INSTANCE.spec.secretStoreRefs.name, value:SecretStore.metadata.name"
+`
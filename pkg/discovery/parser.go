@@ -0,0 +1,300 @@
+package discovery
+
+import (
+	"strings"
+)
+
+// Relationships are declared as a type followed by comma separated name:value
+// fields. CRDs declare them in annotations, which hold just the fields:
+//
+//	resource/label-relationship: "on:Pod, value:INSTANCE.spec.selector"
+//	resource/specproperty-relationship: "on:INSTANCE.spec.volumeName, value:PersistentVolume.metadata.name"
+//	resource/annotation-relationship: "on:Secret; ConfigMap, key:meta.helm.sh/release-name, value:INSTANCE.metadata.name"
+//
+// Built-in kinds also use owner reference relationships:
+//
+//	owner reference, of:Pod, value:INSTANCE.name
+//
+// and scheduling relationships, whose value is a Pod spec for Nodes or the
+// podAffinity, podAntiAffinity or topologySpreadConstraints of one for Pods:
+//
+//	scheduling, on:Node, value:INSTANCE.spec
+//	scheduling, on:Pod, value:INSTANCE.spec.affinity.podAntiAffinity
+
+// Relationship is a parsed relationship declaration.
+type Relationship struct {
+	// label, specproperty, annotation, owner reference or scheduling
+	Type string
+	// Field of the declaring object: the selector of a label relationship,
+	// the referring field of a specproperty relationship, the scheduling
+	// constraints of a scheduling relationship
+	SourcePath string
+	// Kinds at the other end, as written
+	TargetKinds []string
+	// Field of the target: the field a specproperty relationship refers to,
+	// the annotation value of an annotation relationship
+	TargetPath string
+	// Annotation key of an annotation relationship
+	Key string
+}
+
+// Fields a relationship type takes, and whether they are required
+func relationshipFields(relType string) (map[string]bool, bool) {
+	switch relType {
+	case relTypeLabel, relTypeSpecProperty, relTypeScheduling:
+		return map[string]bool{"on": true, "value": true}, true
+	case relTypeAnnotation:
+		return map[string]bool{"on": true, "key": true, "value": true}, true
+	case relTypeOwnerReference:
+		return map[string]bool{"of": true, "value": false}, true
+	}
+	return nil, false
+}
+
+// ParseRelationship parses a relationship declaration such as
+// "specproperty, on:INSTANCE.spec.volumeName, value:PersistentVolume.metadata.name".
+// Errors are *RelationshipError.
+func ParseRelationship(decl string) (Relationship, error) {
+	rel := Relationship{TargetKinds: []string{}}
+	end := strings.Index(decl, ",")
+	if end < 0 {
+		return rel, &RelationshipError{Declaration: decl, Pos: len(decl), Reason: "expected ',' after the relationship type"}
+	}
+	rel.Type = strings.TrimSpace(decl[:end])
+	if _, ok := relationshipFields(rel.Type); !ok {
+		return rel, &RelationshipError{Declaration: decl, Pos: skipSpaces(decl, 0), Reason: "unknown relationship type '" + rel.Type + "'"}
+	}
+	err := parseRelationshipFields(&rel, decl, end+1)
+	return rel, err
+}
+
+// Parses the value of a resource/<type>-relationship annotation, which has
+// the fields of a relationship of relType.
+func parseRelationshipAnnotation(relType, value string) (Relationship, error) {
+	rel := Relationship{Type: relType, TargetKinds: []string{}}
+	err := parseRelationshipFields(&rel, value, 0)
+	return rel, err
+}
+
+// A name:value field; the positions are byte offsets in the declaration.
+type relationshipField struct {
+	name string
+	namePos int
+	value string
+	valuePos int
+}
+
+func parseRelationshipFields(rel *Relationship, decl string, start int) error {
+	fail := func(pos int, reason string) error {
+		return &RelationshipError{Declaration: decl, Pos: pos, Reason: reason}
+	}
+	allowed, ok := relationshipFields(rel.Type)
+	if !ok {
+		return fail(start, "unknown relationship type '"+rel.Type+"'")
+	}
+	fields := make(map[string]relationshipField)
+
+	// Commas inside [] or {} do not end a field
+	depth := 0
+	fieldStart := start
+	for i := start; i <= len(decl); i++ {
+		if i < len(decl) {
+			switch decl[i] {
+			case '[', '{':
+				depth++
+				continue
+			case ']', '}':
+				depth--
+				if depth < 0 {
+					return fail(i, "unbalanced '"+string(decl[i])+"'")
+				}
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		} else if depth > 0 {
+			return fail(i, "missing closing bracket")
+		}
+		field, err := parseRelationshipField(decl, fieldStart, i)
+		if err != nil {
+			return err
+		}
+		if _, ok := allowed[field.name]; !ok {
+			return fail(field.namePos, "unknown field '"+field.name+"' in "+rel.Type+" relationship, expected "+fieldNames(allowed))
+		}
+		if _, ok := fields[field.name]; ok {
+			return fail(field.namePos, "field '"+field.name+"' given twice")
+		}
+		fields[field.name] = field
+		fieldStart = i + 1
+	}
+	for _, name := range []string{"on", "of", "key", "value"} {
+		if _, ok := fields[name]; !ok && allowed[name] {
+			return fail(len(decl), "missing field '"+name+"'")
+		}
+	}
+
+	switch rel.Type {
+	case relTypeLabel, relTypeScheduling:
+		if err := checkKind(decl, fields["on"]); err != nil {
+			return err
+		}
+		if err := checkPath(decl, fields["value"].value, fields["value"].valuePos); err != nil {
+			return err
+		}
+		rel.TargetKinds = append(rel.TargetKinds, fields["on"].value)
+		rel.SourcePath = fields["value"].value
+	case relTypeSpecProperty:
+		on, value := fields["on"], fields["value"]
+		if err := checkPath(decl, on.value, on.valuePos); err != nil {
+			return err
+		}
+		// Kind.field
+		dot := strings.Index(value.value, ".")
+		if dot < 0 {
+			return fail(value.valuePos+len(value.value), "expected Kind.field")
+		}
+		kind := relationshipField{name: "value", value: value.value[:dot], valuePos: value.valuePos}
+		if err := checkKind(decl, kind); err != nil {
+			return err
+		}
+		if err := checkPath(decl, value.value[dot+1:], value.valuePos+dot+1); err != nil {
+			return err
+		}
+		rel.SourcePath = on.value
+		rel.TargetKinds = append(rel.TargetKinds, kind.value)
+		rel.TargetPath = value.value[dot+1:]
+	case relTypeAnnotation:
+		on, key, value := fields["on"], fields["key"], fields["value"]
+		// on:Secret; ConfigMap
+		pos := on.valuePos
+		for _, kind := range strings.Split(on.value, ";") {
+			kindField := relationshipField{name: "on", value: strings.TrimSpace(kind), valuePos: skipSpaces(decl, pos)}
+			if err := checkKind(decl, kindField); err != nil {
+				return err
+			}
+			rel.TargetKinds = append(rel.TargetKinds, kindField.value)
+			pos = pos + len(kind) + 1
+		}
+		rel.Key = key.value
+		// value:INSTANCE.metadata.name or value:[{name:INSTANCE.metadata.name}]
+		path, pathPos := value.value, value.valuePos
+		if strings.HasPrefix(path, "[") {
+			inner := strings.TrimSpace(strings.Trim(path, "[]{} "))
+			colon := strings.Index(inner, ":")
+			if colon < 0 {
+				return fail(value.valuePos, "expected [{name:path}]")
+			}
+			pathPos = value.valuePos + strings.Index(path, inner) + colon + 1
+			path = strings.TrimSpace(inner[colon+1:])
+		}
+		if err := checkPath(decl, path, pathPos); err != nil {
+			return err
+		}
+		rel.TargetPath = path
+	case relTypeOwnerReference:
+		if err := checkKind(decl, fields["of"]); err != nil {
+			return err
+		}
+		rel.TargetKinds = append(rel.TargetKinds, fields["of"].value)
+		rel.SourcePath = fields["value"].value
+	}
+	return nil
+}
+
+// The name:value field in decl[start:end]
+func parseRelationshipField(decl string, start, end int) (relationshipField, error) {
+	field := relationshipField{namePos: skipSpaces(decl, start)}
+	text := decl[start:end]
+	colon := strings.Index(text, ":")
+	if strings.TrimSpace(text) == "" {
+		return field, &RelationshipError{Declaration: decl, Pos: field.namePos, Reason: "expected name:value"}
+	}
+	if colon < 0 {
+		return field, &RelationshipError{Declaration: decl, Pos: field.namePos, Reason: "expected ':' after '" + strings.TrimSpace(text) + "'"}
+	}
+	field.name = strings.TrimSpace(text[:colon])
+	field.value = strings.TrimSpace(text[colon+1:])
+	field.valuePos = skipSpaces(decl, start+colon+1)
+	if field.name == "" {
+		return field, &RelationshipError{Declaration: decl, Pos: field.namePos, Reason: "missing field name before ':'"}
+	}
+	if field.value == "" {
+		return field, &RelationshipError{Declaration: decl, Pos: field.valuePos, Reason: "missing value for '" + field.name + "'"}
+	}
+	return field, nil
+}
+
+// Kinds are written as Kind or Kind.group
+func checkKind(decl string, field relationshipField) error {
+	if field.value == "" {
+		return &RelationshipError{Declaration: decl, Pos: field.valuePos, Reason: "missing kind"}
+	}
+	for i, c := range field.value {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-') {
+			return &RelationshipError{Declaration: decl, Pos: field.valuePos + i, Reason: "invalid character in kind '" + field.value + "'"}
+		}
+	}
+	return nil
+}
+
+// Paths are dot separated field names, e.g. INSTANCE.spec.selector
+func checkPath(decl, path string, pos int) error {
+	for _, name := range strings.Split(path, ".") {
+		if strings.TrimSpace(name) == "" {
+			return &RelationshipError{Declaration: decl, Pos: pos, Reason: "empty field name in '" + path + "'"}
+		}
+		if strings.ContainsAny(name, " \t") {
+			return &RelationshipError{Declaration: decl, Pos: pos, Reason: "space in field name '" + name + "'"}
+		}
+		pos = pos + len(name) + 1
+	}
+	return nil
+}
+
+func skipSpaces(s string, pos int) int {
+	for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
+
+func fieldNames(fields map[string]bool) string {
+	names := make([]string, 0)
+	for _, name := range []string{"on", "of", "key", "value"} {
+		if _, ok := fields[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// The referring path for specproperty, the selector path for label and the
+// constraints path for scheduling relationships, the annotation key for
+// annotation relationships.
+func (rel Relationship) lhs() string {
+	switch rel.Type {
+	case relTypeSpecProperty, relTypeLabel, relTypeScheduling:
+		return rel.SourcePath
+	case relTypeAnnotation:
+		return rel.Key
+	}
+	return ""
+}
+
+// Last field name of the target path for specproperty relationships, the
+// annotation value for annotation relationships.
+func (rel Relationship) rhs() string {
+	switch rel.Type {
+	case relTypeSpecProperty:
+		parts := strings.Split(rel.TargetPath, ".")
+		return parts[len(parts)-1]
+	case relTypeAnnotation:
+		return rel.TargetPath
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package discovery
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseRelationship(t *testing.T) {
+	tests := []struct {
+		decl string
+		want Relationship
+	}{
+		{
+			decl: "label, on:Pod, value:INSTANCE.spec.selector",
+			want: Relationship{Type: "label", SourcePath: "INSTANCE.spec.selector", TargetKinds: []string{"Pod"}},
+		},
+		{
+			decl: "label, on:Certificate.cert-manager.io, value:INSTANCE.spec.selector",
+			want: Relationship{Type: "label", SourcePath: "INSTANCE.spec.selector", TargetKinds: []string{"Certificate.cert-manager.io"}},
+		},
+		{
+			decl: "specproperty, on:INSTANCE.spec.volumeName, value:PersistentVolume.metadata.name",
+			want: Relationship{Type: "specproperty", SourcePath: "INSTANCE.spec.volumeName", TargetKinds: []string{"PersistentVolume"},
+				TargetPath: "metadata.name"},
+		},
+		{
+			// Commas inside brackets do not end the field
+			decl: "specproperty, on:INSTANCE.spec.ports[0,1].name, value:Service.metadata.name",
+			want: Relationship{Type: "specproperty", SourcePath: "INSTANCE.spec.ports[0,1].name", TargetKinds: []string{"Service"},
+				TargetPath: "metadata.name"},
+		},
+		{
+			decl: "annotation, on:Secret; ConfigMap, key:meta.helm.sh/release-name, value:INSTANCE.metadata.name",
+			want: Relationship{Type: "annotation", TargetKinds: []string{"Secret", "ConfigMap"}, TargetPath: "INSTANCE.metadata.name",
+				Key: "meta.helm.sh/release-name"},
+		},
+		{
+			decl: "annotation, on:Secret, key:k, value:[{name:INSTANCE.metadata.name}]",
+			want: Relationship{Type: "annotation", TargetKinds: []string{"Secret"}, TargetPath: "INSTANCE.metadata.name", Key: "k"},
+		},
+		{
+			decl: "owner reference, of:Pod, value:INSTANCE.name",
+			want: Relationship{Type: "owner reference", SourcePath: "INSTANCE.name", TargetKinds: []string{"Pod"}},
+		},
+		{
+			decl: "owner reference, of:ReplicaSet",
+			want: Relationship{Type: "owner reference", TargetKinds: []string{"ReplicaSet"}},
+		},
+		{
+			decl: "scheduling, on:Node, value:INSTANCE.spec",
+			want: Relationship{Type: "scheduling", SourcePath: "INSTANCE.spec", TargetKinds: []string{"Node"}},
+		},
+		{
+			decl: "scheduling, on:Pod, value:INSTANCE.spec.affinity.podAntiAffinity",
+			want: Relationship{Type: "scheduling", SourcePath: "INSTANCE.spec.affinity.podAntiAffinity", TargetKinds: []string{"Pod"}},
+		},
+	}
+	for _, test := range tests {
+		rel, err := ParseRelationship(test.decl)
+		if err != nil {
+			t.Errorf("%q: %v", test.decl, err)
+			continue
+		}
+		if !reflect.DeepEqual(rel, test.want) {
+			t.Errorf("%q: got %+v, want %+v", test.decl, rel, test.want)
+		}
+	}
+}
+
+func TestParseRelationshipErrors(t *testing.T) {
+	tests := []struct {
+		decl   string
+		pos    int
+		reason string
+	}{
+		{"label on:Pod", 12, "expected ',' after the relationship type"},
+		{"  selector, on:Pod, value:INSTANCE.spec.selector", 2, "unknown relationship type 'selector'"},
+		{"label, on:Pod, value:INSTANCE.spec.selector]", 43, "unbalanced ']'"},
+		{"label, on:Pod, value:[{name:INSTANCE.spec.selector}", 51, "missing closing bracket"},
+		// The comma inside the brackets does not split the value field
+		{"label, on:Pod, value:INSTANCE.spec[a,b].selector, foo:bar", 50,
+			"unknown field 'foo' in label relationship, expected on, value"},
+		{"label, on:Pod, on:Service, value:INSTANCE.spec.selector", 15, "field 'on' given twice"},
+		{"label, on:Pod", 13, "missing field 'value'"},
+		{"owner reference, value:INSTANCE.name", 36, "missing field 'of'"},
+		{"label, , on:Pod", 7, "expected name:value"},
+		{"label, on:Pod, value", 15, "expected ':' after 'value'"},
+		{"label, on:, value:INSTANCE.spec.selector", 10, "missing value for 'on'"},
+		{"label, on:Po_d, value:INSTANCE.spec.selector", 12, "invalid character in kind 'Po_d'"},
+		{"label, on:Pod, value:INSTANCE..selector", 30, "empty field name in 'INSTANCE..selector'"},
+		{"label, on:Pod, value:INSTANCE.spec.my selector", 35, "space in field name 'my selector'"},
+		{"specproperty, on:INSTANCE.spec.x, value:Service", 47, "expected Kind.field"},
+		{"specproperty, on:INSTANCE.spec.x, value:Ser/vice.metadata.name", 43, "invalid character in kind 'Ser/vice'"},
+		// Each kind of on:Secret; ConfigMap is checked at its own offset
+		{"annotation, on:Secret; Config_Map, key:k, value:INSTANCE.metadata.name", 29, "invalid character in kind 'Config_Map'"},
+		{"annotation, on:Secret, key:k, value:[{INSTANCE}]", 36, "expected [{name:path}]"},
+		// Paths inside [{name:path}] are checked at their offset in the declaration
+		{"annotation, on:Secret, key:k, value:[{name:INSTANCE..name}]", 52, "empty field name in 'INSTANCE..name'"},
+	}
+	for _, test := range tests {
+		_, err := ParseRelationship(test.decl)
+		if !errors.Is(err, ErrInvalidRelationship) {
+			t.Errorf("%q: got %v, want an invalid relationship", test.decl, err)
+			continue
+		}
+		var relErr *RelationshipError
+		if !errors.As(err, &relErr) {
+			t.Errorf("%q: got %T, want *RelationshipError", test.decl, err)
+			continue
+		}
+		if relErr.Pos != test.pos || relErr.Reason != test.reason || relErr.Declaration != test.decl {
+			t.Errorf("%q: got column %d: %s, want column %d: %s", test.decl, relErr.Pos+1, relErr.Reason, test.pos+1, test.reason)
+		}
+	}
+}
+
+func TestParseRelationshipAnnotation(t *testing.T) {
+	tests := []struct {
+		relType string
+		value   string
+		want    Relationship
+		pos     int
+	}{
+		{relType: "label", value: "on:Pod, value:INSTANCE.spec.selector",
+			want: Relationship{Type: "label", SourcePath: "INSTANCE.spec.selector", TargetKinds: []string{"Pod"}}},
+		{relType: "annotation", value: "on:Secret; ConfigMap, key:meta.helm.sh/release-name, value:INSTANCE.metadata.name",
+			want: Relationship{Type: "annotation", TargetKinds: []string{"Secret", "ConfigMap"}, TargetPath: "INSTANCE.metadata.name",
+				Key: "meta.helm.sh/release-name"}},
+		// Positions are offsets in the annotation value
+		{relType: "annotation", value: "on:Secret;Config Map, key:k, value:INSTANCE.metadata.name", pos: 16},
+		{relType: "specproperty", value: "on:INSTANCE.spec.x", pos: 18},
+	}
+	for _, test := range tests {
+		rel, err := parseRelationshipAnnotation(test.relType, test.value)
+		if test.pos > 0 {
+			var relErr *RelationshipError
+			if !errors.As(err, &relErr) || relErr.Pos != test.pos {
+				t.Errorf("%q: got %v, want an error at column %d", test.value, err, test.pos+1)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(rel, test.want) {
+			t.Errorf("%q: got %+v, want %+v", test.value, rel, test.want)
+		}
+	}
+}
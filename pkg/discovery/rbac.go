@@ -0,0 +1,290 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Permission is access granted through RBAC, with the bindings and roles
+// that grant it.
+type Permission struct {
+	// Namespace the permission holds in, empty when it holds in all namespaces
+	Namespace string
+	APIGroup string
+	// Resource or resource/subresource, "*" for all of them
+	Resource string
+	// Empty for all objects of Resource
+	ResourceNames []string
+	// Set instead of APIGroup and Resource for URLs such as /healthz
+	NonResourceURL string
+	Verbs []string
+	// e.g. "ServiceAccount default/web: RoleBinding default/read-pods -> Role read-pods"
+	Via []string
+}
+
+// Permissions returns what ref may do according to the RBAC objects. ref is a
+// ServiceAccount, a User or Group, or an object that runs Pods such as a Pod
+// or a Deployment, whose ServiceAccount is used. ServiceAccounts and users
+// also hold the permissions of the groups every authenticated user or
+// service account is in.
+func (d *Discoverer) Permissions(ctx context.Context, ref ObjectRef) ([]Permission, error) {
+	q := d.newQuery(ctx)
+	err := q.readKindCompositionFile()
+	if err != nil {
+		return nil, err
+	}
+	// Users and groups are not objects
+	subjects := make([]rbacv1.Subject, 0)
+	bindingNamespaces := q.queryNamespaces(ref.Namespace)
+	switch {
+	case strings.EqualFold(ref.Kind, rbacv1.UserKind):
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.UserKind, Name: ref.Name})
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:authenticated"})
+	case strings.EqualFold(ref.Kind, rbacv1.GroupKind):
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: ref.Name})
+	default:
+		ref, err = q.resolveRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		err = q.checkExistence(ref.Kind, ref.Name, ref.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		serviceAccount := ref.Name
+		if ref.Kind != SERVICE_ACCOUNT {
+			obj, err := q.getKubeObject(ref.Kind, ref.Name, ref.Namespace, q.kindResource(ref.Kind))
+			if err != nil {
+				return nil, err
+			}
+			var ok bool
+			serviceAccount, ok = podServiceAccount(&obj)
+			if !ok {
+				return nil, fmt.Errorf("%s %s does not run Pods", ref.Kind, ref.Name)
+			}
+		}
+		// RoleBindings only grant access in their own namespace
+		bindingNamespaces = []string{ref.Namespace}
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount, Namespace: ref.Namespace})
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.UserKind, Name: "system:serviceaccount:" + ref.Namespace + ":" + serviceAccount})
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:serviceaccounts"})
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:serviceaccounts:" + ref.Namespace})
+		subjects = append(subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:authenticated"})
+	}
+
+	clusterRoles := make(map[string]*rbacv1.ClusterRole)
+	roles := make(map[string]*rbacv1.Role)
+	clusterRoleBindings := make([]*rbacv1.ClusterRoleBinding, 0)
+	roleBindings := make([]*rbacv1.RoleBinding, 0)
+	for _, obj := range q.rbacObjects(CLUSTER_ROLE, []string{""}, &err) {
+		clusterRole := &rbacv1.ClusterRole{}
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), clusterRole) == nil {
+			clusterRoles[clusterRole.Name] = clusterRole
+		}
+	}
+	for _, obj := range q.rbacObjects(CLUSTER_ROLE_BINDING, []string{""}, &err) {
+		binding := &rbacv1.ClusterRoleBinding{}
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), binding) == nil {
+			clusterRoleBindings = append(clusterRoleBindings, binding)
+		}
+	}
+	for _, obj := range q.rbacObjects(ROLE, bindingNamespaces, &err) {
+		role := &rbacv1.Role{}
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), role) == nil {
+			roles[obj.GetNamespace()+"/"+role.Name] = role
+		}
+	}
+	for _, obj := range q.rbacObjects(ROLE_BINDING, bindingNamespaces, &err) {
+		binding := &rbacv1.RoleBinding{}
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), binding) == nil {
+			if binding.Namespace == "" {
+				binding.Namespace = obj.GetNamespace()
+			}
+			roleBindings = append(roleBindings, binding)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]*Permission)
+	grant := func(namespace string, rules []rbacv1.PolicyRule, via string) {
+		for _, rule := range rules {
+			for _, permission := range flattenRule(namespace, rule) {
+				key := strings.Join([]string{permission.Namespace, permission.APIGroup, permission.Resource,
+					strings.Join(permission.ResourceNames, ","), permission.NonResourceURL}, "|")
+				existing, ok := permissions[key]
+				if !ok {
+					existing = &Permission{Namespace: permission.Namespace, APIGroup: permission.APIGroup, Resource: permission.Resource,
+						ResourceNames: permission.ResourceNames, NonResourceURL: permission.NonResourceURL, Verbs: []string{}, Via: []string{}}
+					permissions[key] = existing
+				}
+				for _, verb := range permission.Verbs {
+					if !containsString(existing.Verbs, verb) {
+						existing.Verbs = append(existing.Verbs, verb)
+					}
+				}
+				if !containsString(existing.Via, via) {
+					existing.Via = append(existing.Via, via)
+				}
+			}
+		}
+	}
+	for _, binding := range clusterRoleBindings {
+		subject, ok := boundSubject(binding.Subjects, "", subjects)
+		if !ok || binding.RoleRef.Kind != CLUSTER_ROLE {
+			continue
+		}
+		via := subject + ": " + CLUSTER_ROLE_BINDING + " " + binding.Name + " -> " + CLUSTER_ROLE + " " + binding.RoleRef.Name
+		grant("", clusterRoleRules(clusterRoles, binding.RoleRef.Name, map[string]bool{}), via)
+	}
+	for _, binding := range roleBindings {
+		subject, ok := boundSubject(binding.Subjects, binding.Namespace, subjects)
+		if !ok {
+			continue
+		}
+		via := subject + ": " + ROLE_BINDING + " " + binding.Namespace + "/" + binding.Name + " -> " + binding.RoleRef.Kind + " " + binding.RoleRef.Name
+		switch binding.RoleRef.Kind {
+		case CLUSTER_ROLE:
+			grant(binding.Namespace, clusterRoleRules(clusterRoles, binding.RoleRef.Name, map[string]bool{}), via)
+		case ROLE:
+			if role, ok := roles[binding.Namespace+"/"+binding.RoleRef.Name]; ok {
+				grant(binding.Namespace, role.Rules, via)
+			}
+		}
+	}
+
+	result := make([]Permission, 0)
+	for _, permission := range permissions {
+		sort.Strings(permission.Verbs)
+		result = append(result, *permission)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.NonResourceURL != b.NonResourceURL {
+			return a.NonResourceURL < b.NonResourceURL
+		}
+		if a.APIGroup != b.APIGroup {
+			return a.APIGroup < b.APIGroup
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return strings.Join(a.ResourceNames, ",") < strings.Join(b.ResourceNames, ",")
+	})
+	return result, nil
+}
+
+// Objects of an RBAC kind in namespaces. The first error is kept in err.
+func (q *query) rbacObjects(kind string, namespaces []string, err *error) []*unstructured.Unstructured {
+	objects, listErr := q.getObjects(kind, "*", namespaces, q.kindResource(kind))
+	if listErr != nil && !errors.Is(listErr, ErrNotFound) && *err == nil {
+		*err = listErr
+	}
+	return objects
+}
+
+// The ServiceAccount of the Pods obj runs: its own for a Pod, the one of its
+// Pod template for workloads.
+func podServiceAccount(obj *unstructured.Unstructured) (string, bool) {
+	for _, path := range [][]string{{"spec"}, {"spec", "template", "spec"}, {"spec", "jobTemplate", "spec", "template", "spec"}} {
+		podSpec, found, _ := unstructured.NestedMap(obj.UnstructuredContent(), path...)
+		if !found {
+			continue
+		}
+		if _, ok := podSpec["containers"]; !ok {
+			continue
+		}
+		for _, field := range []string{"serviceAccountName", "serviceAccount"} {
+			if name, ok := podSpec[field].(string); ok && name != "" {
+				return name, true
+			}
+		}
+		return "default", true
+	}
+	return "", false
+}
+
+// The first of subjects that a binding in namespace binds, as Kind name
+func boundSubject(bound []rbacv1.Subject, namespace string, subjects []rbacv1.Subject) (string, bool) {
+	for _, subject := range subjects {
+		for _, b := range bound {
+			if b.Kind != subject.Kind || b.Name != subject.Name {
+				continue
+			}
+			if subject.Kind == rbacv1.ServiceAccountKind {
+				bNamespace := b.Namespace
+				if bNamespace == "" {
+					bNamespace = namespace
+				}
+				if bNamespace != subject.Namespace {
+					continue
+				}
+				return subject.Kind + " " + subject.Namespace + "/" + subject.Name, true
+			}
+			return subject.Kind + " " + subject.Name, true
+		}
+	}
+	return "", false
+}
+
+// Rules of a ClusterRole, with the rules of the ClusterRoles it aggregates.
+// The API server copies those into the rules of an aggregated ClusterRole,
+// but manifests may not have them yet.
+func clusterRoleRules(clusterRoles map[string]*rbacv1.ClusterRole, name string, visited map[string]bool) []rbacv1.PolicyRule {
+	clusterRole, ok := clusterRoles[name]
+	if !ok || visited[name] {
+		return []rbacv1.PolicyRule{}
+	}
+	visited[name] = true
+	rules := append([]rbacv1.PolicyRule{}, clusterRole.Rules...)
+	if clusterRole.AggregationRule == nil {
+		return rules
+	}
+	selectors := make([]*metav1.LabelSelector, 0)
+	for i := range clusterRole.AggregationRule.ClusterRoleSelectors {
+		selectors = append(selectors, &clusterRole.AggregationRule.ClusterRoleSelectors[i])
+	}
+	names := make([]string, 0)
+	for other, _ := range clusterRoles {
+		names = append(names, other)
+	}
+	sort.Strings(names)
+	for _, other := range names {
+		if other != name && matchingSelector(selectors, clusterRoles[other].Labels) != nil {
+			rules = append(rules, clusterRoleRules(clusterRoles, other, visited)...)
+		}
+	}
+	return rules
+}
+
+// A rule for every API group and resource, or non-resource URL, of rule
+func flattenRule(namespace string, rule rbacv1.PolicyRule) []Permission {
+	permissions := make([]Permission, 0)
+	resourceNames := append([]string{}, rule.ResourceNames...)
+	sort.Strings(resourceNames)
+	for _, url := range rule.NonResourceURLs {
+		// Non-resource URLs are only granted by ClusterRoleBindings
+		if namespace == "" {
+			permissions = append(permissions, Permission{NonResourceURL: url, Verbs: rule.Verbs})
+		}
+	}
+	for _, group := range rule.APIGroups {
+		for _, resource := range rule.Resources {
+			permissions = append(permissions, Permission{Namespace: namespace, APIGroup: group, Resource: resource,
+				ResourceNames: resourceNames, Verbs: rule.Verbs})
+		}
+	}
+	return permissions
+}
@@ -2,21 +2,23 @@ package discovery
 
 import (
 	"strings"
+	"strconv"
+	"sort"
 	"fmt"
-	"context"
 	"time"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
-	"k8s.io/client-go/dynamic"
 )
 
 func makeTimestamp() int64 {
     return time.Now().UnixNano() / int64(time.Millisecond)
 }
 
-func GetRelatives(visited [] Connection, level int, kind, instance, origkind, originstance, namespace, relType string) ([]Connection) {
+func (q *query) getRelatives(visited [] Connection, level int, kind, instance, origkind, originstance, namespace, relType string) ([]Connection) {
 	//_ = readKindCompositionFile(kind)
 	/*if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
@@ -25,26 +27,29 @@ func GetRelatives(visited [] Connection, level int, kind, instance, origkind, or
 
 	//fmt.Printf("Node - Level: %d, Kind:%s, instance:%s origkind:%s, originstance:%s relType:%s\n", level, kind, instance, origkind, originstance, relType)
 
-	ignored := checkIgnored(kind, instance)
-	if ignored {
+	ignored := q.checkIgnored(kind, instance)
+	if ignored || q.err != nil {
 		return visited
 	}
 
-	if OutputFormat != "json" {
+	if q.d.options.Progress != nil {
 		_ = makeTimestamp()
-		fmt.Printf("Discovering node - Level: %d, Kind:%s, instance:%s namespace:%s\n", level, kind, instance, namespace)
+		fmt.Fprintf(q.d.options.Progress, "Discovering node - Level: %d, Kind:%s, instance:%s namespace:%s\n", level, kind, instance, namespace)
 	} 
 
-	if kind == "Namespace" {
-		NamespaceToSearch = instance
-		//fmt.Printf("NamespaceToSearch:%s\n", NamespaceToSearch)
+	// Relatives of cluster-scoped objects are searched from the namespace of
+	// the query, and relatives of a Namespace in that namespace.
+	if namespace == "" {
+		namespace = q.orig.Namespace
+	}
+	if kind == NAMESPACE {
 		namespace = instance
 	}
 
 			inputInstance := Connection{
 			Name: instance,
 			Kind: kind,
-			Namespace: namespace,
+			Namespace: q.kindNamespace(kind, namespace),
 			RelationType: relType,
 			Level: level, 
 			Peer: &Connection{
@@ -64,76 +69,105 @@ func GetRelatives(visited [] Connection, level int, kind, instance, origkind, or
 		inputInstanceList := make([]Connection,0)
 		inputInstanceList = append(inputInstanceList, inputInstance)
 		visited = appendCurrentLevelPeers(visited, inputInstanceList)
-		visited = findRelatives(visited, level, kind, instance, origkind, originstance, namespace, relType)
+		visited = q.findRelatives(visited, level, kind, instance, origkind, originstance, namespace, relType)
 	return visited
 }
 
-func findRelatives(visited []Connection, level int, kind, instance, origkind, originstance, namespace string, relType string) ([]Connection) {
-	relStringList := relationshipMap[kind]
+func (q *query) findRelatives(visited []Connection, level int, kind, instance, origkind, originstance, namespace string, relType string) ([]Connection) {
+	relStringList := q.registry.relationshipMap[q.registry.groupKind(kind)]
 	//fmt.Printf("RelationshipMap:%v\n", relationshipMap)
 	//fmt.Printf("Kind:%s, relStringList:%v\n",kind, relStringList)
-	visited = findDownstreamRelatives(visited, level, kind, instance, namespace, relStringList)
+	visited = q.findDownstreamRelatives(visited, level, kind, instance, namespace, relStringList)
 
-	relatedKindList := findRelatedKinds(kind)
+	relatedKindList := q.findRelatedKinds(kind)
 	//fmt.Printf("Kind:%s, Related Kind List 1:%v\n", kind, relatedKindList)
 	for _, relatedKind := range relatedKindList {
-		relStringListRelated := relationshipMap[relatedKind]
+		relStringListRelated := q.registry.relationshipMap[q.registry.groupKind(relatedKind)]
 		//fmt.Printf("RelStringListrelated:%v\n", relStringListRelated)
-		visited = findUpstreamRelatives(visited, level, relatedKind, kind, instance, namespace, relStringListRelated)
+		visited = q.findUpstreamRelatives(visited, level, relatedKind, kind, instance, namespace, relStringListRelated)
+	}
+	if q.d.options.InferReferences {
+		visited = q.findInferredRelatives(visited, level, kind, instance, namespace)
 	}
-	visited = findParentConnections(visited, level, kind, instance, namespace)
-	visited = findChildrenConnections(visited, level, kind, instance, namespace)
-	visited = findCompositionConnections(visited, level, kind, instance, namespace)
+	visited = q.findParentConnections(visited, level, kind, instance, namespace)
+	visited = q.findChildrenConnections(visited, level, kind, instance, namespace)
+	visited = q.findCompositionConnections(visited, level, kind, instance, namespace)
 	return visited
 }
 
-func findDownstreamRelatives(visited []Connection, level int, kind, instance, namespace string, relStringList []string) ([]Connection) {
+func (q *query) findDownstreamRelatives(visited []Connection, level int, kind, instance, namespace string, relStringList []string) ([]Connection) {
 	for _, relString := range relStringList {
-		relType, lhs, rhs, targetKindList := parseRelationship(relString)
+		relType, lhs, rhs, targetKindList, err := q.registry.parseRelationship(kind, relString)
+		if q.fail(err) {
+			return visited
+		}
 		//fmt.Printf("Reltype:%s, lhs:%s, rhs:%s, TargetKindList:%v\n", relType, lhs, rhs, targetKindList)
 		for _, targetKind := range targetKindList {
 			if relType == relTypeLabel {
 				//fmt.Printf("Kind:%s, Instance:%s, Namespace:%s TargetKind:%s\n", kind, instance, namespace, targetKind)
-				selectorLabelMap := getSelectorLabels(kind, instance, namespace)
-				//fmt.Printf("Selector LabelMap:%v\n", selectorLabelMap)
-				relativesNames, relDetail := searchLabels(level, kind, instance, selectorLabelMap, targetKind, namespace)
+				selectors, err := q.getSelectors(kind, instance, namespace, lhs)
+				if q.fail(err) {
+					return visited
+				}
+				// An empty list of selectors selects nothing
+				if len(selectors) == 0 && strings.HasSuffix(lhs, "[*]") {
+					continue
+				}
+				//fmt.Printf("Selector:%v\n", selector)
+				relativesNames, relDetail, err := q.searchLabels(level, kind, instance, selectors, targetKind, namespace)
+				if q.fail(err) {
+					return visited
+				}
 				//fmt.Printf("FDSR label - Relnames:%v RelDetail:%v\n", relativesNames, relDetail)
-				visited = buildGraph(visited, level, kind, instance, relativesNames, targetKind, namespace, relType, relDetail)
+				visited = q.buildGraph(visited, level, kind, instance, relativesNames, targetKind, namespace, relType, relDetail)
 			}
 			if relType == relTypeSpecProperty {
 				targetInstance := "*"
-				relativesNames, relDetail, relTypeSpecific := searchSpecProperty(level, kind, instance, namespace, lhs, rhs, targetKind, targetInstance)
+				relativesNames, relDetail, relTypeSpecific, err := q.searchSpecProperty(level, kind, instance, namespace, lhs, rhs, targetKind, targetInstance)
+				if q.fail(err) {
+					return visited
+				}
 				relType = relTypeSpecific
 				//fmt.Printf("FDSR Spec - Relnames:%v Relatives:%v\n", relativesNames, relatives)
-				visited = buildGraph(visited, level, kind, instance, relativesNames, targetKind, namespace, relType, relDetail)
+				visited = q.buildGraph(visited, level, kind, instance, relativesNames, targetKind, namespace, relType, relDetail)
 			}
 			if relType == relTypeAnnotation {
 				targetInstance := "*"
 				//fmt.Printf("kind:%s instance:%s targetkind:%s targetInstance:%s ns:%s\n", kind, instance, targetKind, targetInstance, namespace)
-				relativesNames, relDetail := searchAnnotations(level, kind, instance, namespace, lhs, rhs, targetKind, targetInstance)
+				relativesNames, relDetail, err := q.searchAnnotations(level, kind, instance, namespace, lhs, rhs, targetKind, targetInstance)
+				if q.fail(err) {
+					return visited
+				}
 				//fmt.Printf("FDSR Annotation:%v\n", relativesNames)				
-				visited = buildGraph(visited, level, kind, instance, relativesNames, targetKind, namespace, relType, relDetail)
+				visited = q.buildGraph(visited, level, kind, instance, relativesNames, targetKind, namespace, relType, relDetail)
+			}
+			if relType == relTypeScheduling {
+				targetInstance := "*"
+				relativesNames, relDetail, err := q.searchScheduling(level, kind, instance, namespace, lhs, targetKind, targetInstance)
+				if q.fail(err) {
+					return visited
+				}
+				visited = q.buildGraph(visited, level, kind, instance, relativesNames, targetKind, namespace, relType, relDetail)
 			}
 		}
 	}
 	return visited
 }
 
-func checkIgnored(kind, instance string) bool {
+func (q *query) checkIgnored(kind, instance string) bool {
 	//ignoredRelsString := strings.Split(RelsToIgnore, "=")
 	//fmt.Printf("IgnoredRelsString:%s\n", ignoredRelsString[1])
 	//if len(ignoredRelsString) > 1 {
-		ignoredRels := strings.Split(RelsToIgnore, ",")
-		fqinstance := kind + ":" + instance
+		ignoredRels := strings.Split(q.d.options.Ignore, ",")
 		for _, rel := range ignoredRels {
-			//fmt.Printf("Ignored:%s, FQInstance:%s\n", rel, fqinstance)
+			//fmt.Printf("Ignored:%s, Kind:%s, Instance:%s\n", rel, kind, instance)
 			parts := strings.Split(rel, ":")
 			if len(parts) > 1 {
-				if parts[1] == "*" {
-					if kind == parts[0] {
-						return true
-					}
-				} else if fqinstance == rel {
+				// Kinds may be given in any form accepted on the command line
+				if q.registry.groupKind(parts[0]) != q.registry.groupKind(kind) {
+					continue
+				}
+				if parts[1] == "*" || parts[1] == instance {
 					return true
 				}
 			}
@@ -142,31 +176,54 @@ func checkIgnored(kind, instance string) bool {
 	return false
 }
 
-func findUpstreamRelatives(visited []Connection, level int, relatedKind, kind, instance, namespace string, relStringList []string) ([]Connection) {
+func (q *query) findUpstreamRelatives(visited []Connection, level int, relatedKind, kind, instance, namespace string, relStringList []string) ([]Connection) {
 	for _, relString := range relStringList {
-		relType, lhs, rhs, targetKindList := parseRelationship(relString)
+		relType, lhs, rhs, targetKindList, err := q.registry.parseRelationship(relatedKind, relString)
+		if q.fail(err) {
+			return visited
+		}
 		for _, targetKind := range targetKindList {
 			if targetKind == kind {
 				if relType == relTypeLabel {
 					//fmt.Printf("ABC - Kind:%s, Instance:%s, Namespace%s", kind, instance, namespace)
-					labelMap := getLabels(kind, instance, namespace)
+					labelMap, err := q.getLabels(kind, instance, namespace)
+					if q.fail(err) {
+						return visited
+					}
 					//fmt.Printf("ABC - labelMap:%v\n", labelMap)
-					relativesNames, relDetail := searchSelectors(level, relatedKind, labelMap, kind, instance, namespace)
+					relativesNames, relDetail, err := q.searchSelectors(level, relatedKind, lhs, labelMap, kind, instance, namespace)
+					if q.fail(err) {
+						return visited
+					}
 					//fmt.Printf("FUSR label - Relnames:%v Relatives:%v\n", relativesNames, relDetail)
-					visited = buildGraph(visited, level, kind, instance, relativesNames, relatedKind, namespace, relType, relDetail)
+					visited = q.buildGraph(visited, level, kind, instance, relativesNames, relatedKind, namespace, relType, relDetail)
 				}
 				if relType == relTypeSpecProperty {
 					targetInstance := "*"
-					relativesNames, relDetail, relTypeSpecific := searchSpecProperty(level, relatedKind, targetInstance, namespace, lhs, rhs, kind, instance)
+					relativesNames, relDetail, relTypeSpecific, err := q.searchSpecProperty(level, relatedKind, targetInstance, namespace, lhs, rhs, kind, instance)
+					if q.fail(err) {
+						return visited
+					}
 					//fmt.Printf("FUSR Spec - Relnames:%v Relatives:%v\n", relativesNames, relatives)
 					relType = relTypeSpecific
-					visited = buildGraph(visited, level, kind, instance, relativesNames, relatedKind, namespace, relType, relDetail)
+					visited = q.buildGraph(visited, level, kind, instance, relativesNames, relatedKind, namespace, relType, relDetail)
 				}
 				if relType == relTypeAnnotation {
 					targetInstance := "*"
-					relativesNames, relDetail := searchAnnotations(level, relatedKind, targetInstance, namespace, lhs, rhs, kind, instance)
+					relativesNames, relDetail, err := q.searchAnnotations(level, relatedKind, targetInstance, namespace, lhs, rhs, kind, instance)
+					if q.fail(err) {
+						return visited
+					}
 					//fmt.Printf("FDSR Annotation:%v\n", relativesNames)				
-					visited = buildGraph(visited, level, kind, instance, relativesNames, relatedKind, namespace, relType, relDetail)
+					visited = q.buildGraph(visited, level, kind, instance, relativesNames, relatedKind, namespace, relType, relDetail)
+				}
+				if relType == relTypeScheduling {
+					targetInstance := "*"
+					relativesNames, relDetail, err := q.searchScheduling(level, relatedKind, targetInstance, namespace, lhs, kind, instance)
+					if q.fail(err) {
+						return visited
+					}
+					visited = q.buildGraph(visited, level, kind, instance, relativesNames, relatedKind, namespace, relType, relDetail)
 				}
 			}
 		}
@@ -174,17 +231,17 @@ func findUpstreamRelatives(visited []Connection, level int, relatedKind, kind, i
 	return visited
 }
 
-func buildGraph(visited []Connection, level int, kind, instance string, relativesNames []Connection, targetKind, namespace, relType, relDetail string) ([]Connection) {
+func (q *query) buildGraph(visited []Connection, level int, kind, instance string, relativesNames []Connection, targetKind, namespace, relType, relDetail string) ([]Connection) {
 	unseenRelatives, seenRelatives := filterConnections(visited, relativesNames)
 
 	/*fmt.Printf("unseenRelatives:%v\n", unseenRelatives)
 	fmt.Printf("seenRelatives:%v\n", seenRelatives)*/
 
 	visited = appendCurrentLevelPeers(visited, relativesNames)
-	visited = searchNextLevel(visited, level, unseenRelatives, kind, instance, targetKind, namespace, relType)
+	visited = q.searchNextLevel(visited, level, unseenRelatives, kind, instance, targetKind, namespace, relType)
 
 	for _, conn := range seenRelatives {
-		TotalClusterConnections = AppendConnections(TotalClusterConnections, conn)
+		q.connections = AppendConnections(q.connections, conn)
 	}
 
 	return visited
@@ -219,10 +276,10 @@ func appendCurrentLevelPeers(connections, currentConnections []Connection) ([]Co
 	return connections
 }
 
-func findCompositionConnections(visited []Connection, level int, kind, instance, namespace string) []Connection {
-	if _, ok := crdcompositionMap[kind]; ok {
+func (q *query) findCompositionConnections(visited []Connection, level int, kind, instance, namespace string) []Connection {
+	if _, ok := q.registry.crdcompositionMap[q.registry.groupKind(kind)]; ok {
 
-	composition := TotalClusterCompositions.GetCompositions(kind, instance, namespace)
+	composition := q.compositions.GetCompositions(kind, instance, q.kindNamespace(kind, namespace))
 	childrenConnections := make([]Connection, 0)
 	//fmt.Printf("Kind:%s Instance:%s\n", kind, instance)
 	//fmt.Printf("Composition:%v\n", composition)
@@ -242,7 +299,7 @@ func findCompositionConnections(visited []Connection, level int, kind, instance,
 					Peer: &Connection{
 						Kind: kind,
 						Name: instance,
-						Namespace: namespace,
+						Namespace: q.kindNamespace(kind, namespace),
 					},
 					Level: level,
 				}
@@ -258,38 +315,39 @@ func findCompositionConnections(visited []Connection, level int, kind, instance,
 		for _, conn := range childrenToSearch {
 				relType := relTypeOwnerReference
 				//fmt.Printf("Conn.Kind:%s Conn.Name:%s kind:%s instance:%s\n", conn.Kind, conn.Name, kind, instance)
-				TotalClusterConnections = AppendConnections(TotalClusterConnections, conn)
-				if NamespaceToSearch != "" {
-					namespace = NamespaceToSearch
-				}
-				visited = GetRelatives(visited, level, conn.Kind, conn.Name, kind, instance, namespace, relType)
+				q.connections = AppendConnections(q.connections, conn)
+				visited = q.getRelatives(visited, level, conn.Kind, conn.Name, kind, instance, conn.Namespace, relType)
 		}
 	}
 
 	for _, conn := range seenRelatives {
 		//fmt.Printf("4\n")
-		TotalClusterConnections = AppendConnections(TotalClusterConnections, conn)
+		q.connections = AppendConnections(q.connections, conn)
 	}
 	}
 	return visited
 }
 
-func findParentConnections(visited []Connection, level int, kind, instance, namespace string) []Connection {
-	ownerKind, ownerInstance := getOwnerDetail(kind, instance, namespace)
+func (q *query) findParentConnections(visited []Connection, level int, kind, instance, namespace string) []Connection {
+	ownerKind, ownerInstance, err := q.getOwnerDetail(kind, instance, namespace)
+	if q.fail(err) {
+		return visited
+	}
 	//fmt.Printf("Kind:%s Instance:%s\n", kind, instance)
 	//fmt.Printf("OKind:%s OInstance:%s\n", ownerKind, ownerInstance)
 	peer := Connection{
 				Kind: kind,
 				Name: instance,
-				Namespace: namespace,
+				Namespace: q.kindNamespace(kind, namespace),
 				RelationType: relTypeOwnerReference,
 	}
 	//fmt.Printf("Kind:%s Instance:%s OwnerKind:%s OwnerInstance:%s\n", kind, instance, ownerKind, ownerInstance)
 	if ownerKind != "" && ownerInstance != "" {
+		// Owners are in the namespace of the object, or cluster-scoped
 		ownerConn := Connection{
 			Name: ownerInstance,
 			Kind: ownerKind,
-			Namespace: namespace,
+			Namespace: q.kindNamespace(ownerKind, namespace),
 			RelationType: relTypeOwnerReference,
 			Level: level,
 		}
@@ -314,41 +372,42 @@ func findParentConnections(visited []Connection, level int, kind, instance, name
 			for _, conn := range ownerToSearch {
 				relType := relTypeOwnerReference
 				//fmt.Printf("ABC:%v\n", conn)
-				TotalClusterConnections = AppendConnections(TotalClusterConnections, conn)
-				if NamespaceToSearch != "" {
-					namespace = NamespaceToSearch
-				}
-				visited = GetRelatives(visited, level, conn.Kind, conn.Name, kind, instance, namespace, relType)
+				q.connections = AppendConnections(q.connections, conn)
+				visited = q.getRelatives(visited, level, conn.Kind, conn.Name, kind, instance, conn.Namespace, relType)
 			}
 		}
 		for _, conn := range seenRelatives {
 			//fmt.Printf("4 conn:%v\n", conn)
-			TotalClusterConnections = AppendConnections(TotalClusterConnections, conn)
+			q.connections = AppendConnections(q.connections, conn)
 		}
 	}
 	return visited
 }
 
-func findChildrenConnections(visited []Connection, level int, kind, instance, namespace string) []Connection {
-	relatedKindList := findChildKinds(kind)
+func (q *query) findChildrenConnections(visited []Connection, level int, kind, instance, namespace string) []Connection {
+	relatedKindList := q.findChildKinds(kind)
 	//fmt.Printf("Child Kinds:%s\n", relatedKindList)
 	childs := make([]Connection,0)
 	peer := Connection{
 				Kind: kind,
 				Name: instance,
-				Namespace: namespace,
+				Namespace: q.kindNamespace(kind, namespace),
 				RelationType: relTypeOwnerReference,
 	}
 	for _, relKind := range relatedKindList {
-		childResKindPlural, _, childResApiVersion, childResGroup := getKindAPIDetails(relKind)
+		childResKindPlural, _, childResApiVersion, childResGroup := q.registry.getKindAPIDetails(relKind)
 		childRes := schema.GroupVersionResource{Group: childResGroup,
 										 		Version: childResApiVersion,
 										   		Resource: childResKindPlural}
 		//dynamicClient, err := getDynamicClient()
 
-		children, err := getKubeObjectList(relKind, namespace, childRes)
+		// Children of cluster-scoped owners may be in any namespace of the query
+		children, err := q.getObjects(relKind, "*", q.relatedNamespaces(kind, namespace), childRes)
+		if q.fail(err) {
+			return visited
+		}
 		if err != nil {
-				return visited
+			continue
 		}
 		/*
 		children, err := dynamicClient.Resource(childRes).Namespace(namespace).List(context.TODO(),
@@ -360,13 +419,13 @@ func findChildrenConnections(visited []Connection, level int, kind, instance, na
 				return visited
 			}
 		}*/
-		for _, child := range children.Items {
-			ownerKind, ownerInstance := findOwner(child)
+		for _, child := range children {
+			ownerKind, ownerInstance := findOwner(*child)
 			if ownerKind == kind && ownerInstance == instance {
 				connection := Connection {
 					Name: child.GetName(),
 					Kind: relKind,
-					Namespace: namespace,
+					Namespace: q.objectNamespace(relKind, child, namespace),
 					RelationType: relTypeOwnerReference,
 					Level: level,
 					Peer: &peer,
@@ -386,30 +445,24 @@ func findChildrenConnections(visited []Connection, level int, kind, instance, na
 			relType := relTypeOwnerReference
 			if conn.Kind != "" && conn.Name != "" {
 				//fmt.Printf("ABC:%v\n", conn)
-				TotalClusterConnections = AppendConnections(TotalClusterConnections, conn)
-				if NamespaceToSearch != "" {
-					namespace = NamespaceToSearch
-				}
-				visited = GetRelatives(visited, level, conn.Kind, conn.Name, kind, instance, namespace, relType)
+				q.connections = AppendConnections(q.connections, conn)
+				visited = q.getRelatives(visited, level, conn.Kind, conn.Name, kind, instance, conn.Namespace, relType)
 			}
 		}
 	}
 
 	for _, conn := range seenRelatives {
-		TotalClusterConnections = AppendConnections(TotalClusterConnections, conn)
+		q.connections = AppendConnections(q.connections, conn)
 	}
 	return visited
 }
 
-func searchNextLevel(visited []Connection, level int, relativeNames []Connection, kind, instance, targetKind, namespace, relType string) ([]Connection) {
+func (q *query) searchNextLevel(visited []Connection, level int, relativeNames []Connection, kind, instance, targetKind, namespace, relType string) ([]Connection) {
 	level = level + 1
 	for _, relative := range relativeNames {
 		relativeName := relative.Name
-		TotalClusterConnections = AppendConnections(TotalClusterConnections, relative)
-		if NamespaceToSearch != "" {
-			namespace = NamespaceToSearch
-		}
-		visited = GetRelatives(visited, level, targetKind, relativeName, kind, instance, namespace, relType)
+		q.connections = AppendConnections(q.connections, relative)
+		visited = q.getRelatives(visited, level, targetKind, relativeName, kind, instance, relative.Namespace, relType)
 	}
 	return visited
 }
@@ -428,10 +481,10 @@ func findOwner(instanceObj unstructured.Unstructured) (string, string) {
 	return ownerKind, ownerName
 }
 
-func getOwnerDetail(kind, instance, namespace string) (string, string) {
+func (q *query) getOwnerDetail(kind, instance, namespace string) (string, string, error) {
 	ownerKind := ""
 	ownerInstance := ""
-	ownerResKindPlural, _, ownerResApiVersion, ownerResGroup := getKindAPIDetails(kind)
+	ownerResKindPlural, _, ownerResApiVersion, ownerResGroup := q.registry.getKindAPIDetails(kind)
 	ownerRes := schema.GroupVersionResource{Group: ownerResGroup,
 									 		Version: ownerResApiVersion,
 									   		Resource: ownerResKindPlural}
@@ -443,49 +496,41 @@ func getOwnerDetail(kind, instance, namespace string) (string, string) {
 																			 	  instance,
 															   		 	  metav1.GetOptions{})
 	*/
-	instanceObj, err := getKubeObject(kind, instance, namespace, ownerRes)
+	instanceObj, err := q.getKubeObject(kind, instance, namespace, ownerRes)
 	if err != nil {
-		return ownerKind, ownerInstance
+		return ownerKind, ownerInstance, err
 	}
 	ownerKind, ownerInstance = findOwner(instanceObj)
-	return ownerKind, ownerInstance
+	return ownerKind, ownerInstance, nil
 }
 
-func searchAnnotations(level int, kind, instance, namespace, annotationKey, annotationValue, targetKind, targetInstance string) ([]Connection, string) {
+func (q *query) searchAnnotations(level int, kind, instance, namespace, annotationKey, annotationValue, targetKind, targetInstance string) ([]Connection, string, error) {
 	relativesNames := make([]Connection, 0)
 	relDetail := ""
-	dynamicClient, err := getDynamicClient()
-	if err != nil {
-		return relativesNames, relDetail
-	}
-	lhsResKindPlural, _, lhsResApiVersion, lhsResGroup := getKindAPIDetails(kind)
+	lhsResKindPlural, _, lhsResApiVersion, lhsResGroup := q.registry.getKindAPIDetails(kind)
 	lhsRes := schema.GroupVersionResource{Group: lhsResGroup,
 									   Version: lhsResApiVersion,
 									   Resource: lhsResKindPlural}
 	//fmt.Printf("%v\n", lhsRes)
 	lhsNamespace := namespace
-	if kind == OriginalInputKind && instance == OriginalInputInstance {
-		lhsNamespace = OriginalInputNamespace
+	if kind == q.orig.Kind && instance == q.orig.Name {
+		lhsNamespace = q.orig.Namespace
 	}
-	lhsInstList, err := getObjects(kind, instance, lhsNamespace, lhsRes, dynamicClient)
+	lhsInstList, err := q.getObjects(kind, instance, q.relatedNamespaces(targetKind, lhsNamespace), lhsRes)
 	if err != nil {
 		//fmt.Printf("lhsInstList:%v", err)
-		return relativesNames, relDetail
+		return relativesNames, relDetail, err
 	}
 
-	rhsResKindPlural, _, rhsResApiVersion, rhsResGroup := getKindAPIDetails(targetKind)
+	rhsResKindPlural, _, rhsResApiVersion, rhsResGroup := q.registry.getKindAPIDetails(targetKind)
 	rhsRes := schema.GroupVersionResource{Group: rhsResGroup,
 									   Version: rhsResApiVersion,
 									   Resource: rhsResKindPlural}
 	//fmt.Printf("RHSRes:%v\n", rhsRes)
-	rhsNamespace := namespace
-	if NamespaceToSearch != "" {
-		rhsNamespace = NamespaceToSearch
-	}
-	rhsInstList, err := getObjects(targetKind, targetInstance, rhsNamespace, rhsRes, dynamicClient)
+	rhsInstList, err := q.getObjects(targetKind, targetInstance, q.relatedNamespaces(kind, namespace), rhsRes)
 	if err != nil {
 		//fmt.Printf("rhsInstList:%v", err)
-		return relativesNames, relDetail
+		return relativesNames, relDetail, err
 	}
 	//fmt.Printf("RHSList:%v\n", rhsInstList)
 
@@ -494,6 +539,9 @@ func searchAnnotations(level int, kind, instance, namespace, annotationKey, anno
 		lhsName := instanceObj.GetName()
 
 		for _, unstructuredObj := range rhsInstList {
+			if !sameNamespace(instanceObj, unstructuredObj) {
+				continue
+			}
 			//rhsName := unstructuredObj.GetName()
 			//fmt.Printf("RHS Name:%s\n", rhsName)
 			//rhsContent := unstructuredObj.UnstructuredContent()
@@ -516,13 +564,13 @@ func searchAnnotations(level int, kind, instance, namespace, annotationKey, anno
 							Level: level,
 							Name: lhsName,
 							Kind: kind,
-							Namespace: namespace,
+							Namespace: q.objectNamespace(kind, instanceObj, namespace),
 							RelationDetails: relDetail,
 							RelationType: relTypeAnnotation,
 							Peer: &Connection{
 								Name: targetInstance,
 								Kind: targetKind,
-								Namespace: namespace,
+								Namespace: q.objectNamespace(targetKind, unstructuredObj, namespace),
 							},
 						}
 						relativesNames = append(relativesNames, conn)
@@ -538,13 +586,13 @@ func searchAnnotations(level int, kind, instance, namespace, annotationKey, anno
 							Level: level,
 							Name: rhsInstanceName,
 							Kind: targetKind,
-							Namespace: namespace,
+							Namespace: q.objectNamespace(targetKind, unstructuredObj, namespace),
 							RelationDetails: relDetail,
 							RelationType: relTypeAnnotation,
 							Peer: &Connection{
 								Name: instance,
 								Kind: kind,
-								Namespace: namespace,
+								Namespace: q.objectNamespace(kind, instanceObj, namespace),
 							},
 						}
 						relativesNames = append(relativesNames, conn)
@@ -553,291 +601,318 @@ func searchAnnotations(level int, kind, instance, namespace, annotationKey, anno
 			}
 		}
 	}
-	return relativesNames, relDetail
+	return relativesNames, relDetail, nil
 }
 
-func searchSpecProperty(level int, kind, instance, namespace, lhs, rhs, targetKind, targetInstance string) ([]Connection, string, string) {
+func (q *query) searchSpecProperty(level int, kind, instance, namespace, lhs, rhs, targetKind, targetInstance string) ([]Connection, string, string, error) {
 	relativesNames := make([]Connection, 0)
 	envNameValue := ""
 	relTypeSpecific := ""
-	if lhs == "env" {
-		relativesNames, envNameValue = searchSpecPropertyEnv(level, kind, instance, namespace, rhs, targetKind, targetInstance)
+	var err error
+	if lastField(lhs) == "env" {
+		relativesNames, envNameValue, err = q.searchSpecPropertyEnv(level, kind, instance, namespace, rhs, targetKind, targetInstance)
 		relTypeSpecific = relTypeEnvvariable
 	} else {
-		relativesNames, envNameValue = searchSpecPropertyField(level, kind, instance, namespace, lhs, rhs, targetKind, targetInstance)		
+		relativesNames, envNameValue, err = q.searchSpecPropertyField(level, kind, instance, namespace, lhs, rhs, targetKind, targetInstance)		
 		relTypeSpecific = relTypeSpecProperty
 	}
-	return relativesNames, envNameValue, relTypeSpecific
+	return relativesNames, envNameValue, relTypeSpecific, err
 }
 
-func searchSpecPropertyField(level int, kind, instance, namespace, lhs, rhs, targetKind, targetInstance string) ([]Connection, string) {
+func (q *query) searchSpecPropertyField(level int, kind, instance, namespace, lhs, rhs, targetKind, targetInstance string) ([]Connection, string, error) {
 	relativesNames := make([]Connection, 0)
 	propertyNameValue := ""
 
-	dynamicClient, err := getDynamicClient()
-
-	lhsResKindPlural, _, lhsResApiVersion, lhsResGroup := getKindAPIDetails(kind)
+	lhsResKindPlural, _, lhsResApiVersion, lhsResGroup := q.registry.getKindAPIDetails(kind)
 	lhsRes := schema.GroupVersionResource{Group: lhsResGroup,
 									   Version: lhsResApiVersion,
 									   Resource: lhsResKindPlural}
+	// Objects in other namespaces of the query can refer to the target by
+	// giving its namespace
+	lhsInstList, err := q.getObjects(kind, instance, q.queryNamespaces(namespace), lhsRes)
 	if err != nil {
-		return relativesNames, propertyNameValue
+		return relativesNames, propertyNameValue, err
 	}
-	lhsInstList, err := getObjects(kind, instance, namespace, lhsRes, dynamicClient)
-	if err != nil {
-		return relativesNames, propertyNameValue
+
+	rhsNamespaces := q.relatedNamespaces(kind, namespace)
+	lhsRefs := make([][]fieldReference, len(lhsInstList))
+	for i, instanceObj := range lhsInstList {
+		lhsRefs[i] = findFieldReferences(instanceObj.UnstructuredContent(), lhs)
+		if lastField(lhs) == "externalName" {
+			for j, ref := range lhsRefs[i] {
+				lhsRefs[i][j] = q.serviceReference(ref.Value)
+			}
+		}
+		rhsNamespaces = addReferencedNamespaces(rhsNamespaces, lhsRefs[i])
 	}
 
-	rhsResKindPlural, _, rhsResApiVersion, rhsResGroup := getKindAPIDetails(targetKind)
+	rhsResKindPlural, _, rhsResApiVersion, rhsResGroup := q.registry.getKindAPIDetails(targetKind)
 	rhsRes := schema.GroupVersionResource{Group: rhsResGroup,
 									   Version: rhsResApiVersion,
 									   Resource: rhsResKindPlural}
-	rhsNamespace := namespace
-	if targetKind == "Namespace" {
-		rhsNamespace = OriginalInputNamespace
-	}
-	//fmt.Printf("TargetKind:%s, TargetInstance:%s rhsNamespace:%s\n", targetKind, targetInstance, rhsNamespace)
-	rhsInstList, err := getObjects(targetKind, targetInstance, rhsNamespace, rhsRes, dynamicClient)
+	//fmt.Printf("TargetKind:%s, TargetInstance:%s namespace:%s\n", targetKind, targetInstance, namespace)
+	rhsInstList, err := q.getObjects(targetKind, targetInstance, rhsNamespaces, rhsRes)
 	//fmt.Printf("RhsInstList:%v\n", rhsInstList)
 	if err != nil {
 		//fmt.Printf("Error:%v\n", err)
-		return relativesNames, propertyNameValue
+		return relativesNames, propertyNameValue, err
 	}
 	//fmt.Printf("LHSObj:%v\n", lhsInstList)
 	//fmt.Printf("LHSKind:%s lhs:%s namespace:%s\n", kind, lhs, namespace)
-	for _, instanceObj := range lhsInstList {
-		lhsContent := instanceObj.UnstructuredContent()
+	for i, instanceObj := range lhsInstList {
 		lhsName := instanceObj.GetName()
-		//fmt.Printf("LHSContent:%v\n", lhsContent)
 		//fmt.Printf("LHSName:%s\n",lhsName)
-		var fieldValue string
-		var found bool
-		if lhs == "namespace" {
-			fieldValue = instanceObj.GetNamespace()
-			//fmt.Printf("FieldValue:%s\n", fieldValue)
-			found = true
-		} else {
-			fieldValue, found = findFieldValue(lhsContent, lhs)
-		}
 		//fieldValue, found, err := unstructured.NestedString(lhsContent, "spec", lhs)
 		//fmt.Printf("FieldValue:%s, found:%v, Error:%v", fieldValue, found, err)
 		//if err != nil || !found {
 		//	return relativesNames, propertyNameValue
 		//}
-		//fmt.Printf("ABC ABC: %v, %s", found, fieldValue)
-		if found {
+		for _, ref := range lhsRefs[i] {
+			fieldValue := ref.Value
 			for _, unstructuredObj := range rhsInstList {
 				//fmt.Printf(" 444 %s\n", unstructuredObj.GetName())
-				if rhs == "name" {
-					rhsInstanceName := unstructuredObj.GetName()
-					if fieldValue == rhsInstanceName {
-						var connName, connKind string
-						var peerName, peerKind string
-						if instance == "*" {
-							connName = lhsName
-							connKind = kind
-							peerName = rhsInstanceName
-							peerKind = targetKind
-							//relativesNames = append(relativesNames, lhsName)
-						} else {
-							connName = rhsInstanceName
-							connKind = targetKind
-							peerName = lhsName
-							peerKind = kind
-							//relativesNames = append(relativesNames, rhsInstanceName)
-						}
-						propertyNameValue = "Name:" + lhs + " " + "Value:" + fieldValue
-						conn := Connection{
-							Level: level,
-							Name: connName,
-							Kind: connKind,
-							Namespace: namespace,
-							RelationDetails: propertyNameValue,
-							RelationType: relTypeSpecProperty,
-							Peer: &Connection{
-								Name: peerName,
-								Kind: peerKind,
-								Namespace: namespace,
-							},
-						}
-						relativesNames = append(relativesNames, conn)
-						if found {
-							break
-						}
+				rhsInstanceName := unstructuredObj.GetName()
+				if rhs == "name" && fieldValue == rhsInstanceName && q.kindMatches(ref, targetKind) && q.namespaceMatches(ref, instanceObj, targetKind, unstructuredObj) {
+					var connName, connKind, connNamespace string
+					var peerName, peerKind, peerNamespace string
+					lhsNamespace := q.objectNamespace(kind, instanceObj, namespace)
+					rhsNamespace := q.objectNamespace(targetKind, unstructuredObj, namespace)
+					if instance == "*" {
+						connName, connKind, connNamespace = lhsName, kind, lhsNamespace
+						peerName, peerKind, peerNamespace = rhsInstanceName, targetKind, rhsNamespace
+						//relativesNames = append(relativesNames, lhsName)
+					} else {
+						connName, connKind, connNamespace = rhsInstanceName, targetKind, rhsNamespace
+						peerName, peerKind, peerNamespace = lhsName, kind, lhsNamespace
+						//relativesNames = append(relativesNames, rhsInstanceName)
+					}
+					propertyNameValue = "Name:" + ref.Path + " " + "Value:" + fieldValue
+					if ref.Namespace != "" {
+						propertyNameValue = propertyNameValue + " " + "Namespace:" + ref.Namespace
+					}
+					conn := Connection{
+						Level: level,
+						Name: connName,
+						Kind: connKind,
+						Namespace: connNamespace,
+						RelationDetails: propertyNameValue,
+						RelationType: relTypeSpecProperty,
+						Peer: &Connection{
+							Name: peerName,
+							Kind: peerKind,
+							Namespace: peerNamespace,
+						},
 					}
+					relativesNames = append(relativesNames, conn)
+					break
 				}
 			}
 		}
 	}
-	return relativesNames, propertyNameValue
+	return relativesNames, propertyNameValue, nil
 }
 
-func findFieldValue(lhsContent interface{}, specfield string) (string, bool) {
-	fieldValue := ""
-	found := false
-	//fmt.Printf("lhsContent:%v\n", lhsContent)
-	mapval, ok1 := lhsContent.(map[string]interface{})
-	if ok1 {
-		for key, value := range mapval {
-			if found {
-				break
-			}
-			//fmt.Printf("Key:%s, Value:%v\n", key, value)
-			stringval, ok := value.(string)
-			if ok {
-				if key == specfield {
-					//fmt.Printf("Field:%s Value:%s\n", key, stringval)
-					fieldValue = stringval
-					found = true
-					break
-				}
-			} 
-			mapval1, ok1 := value.(map[string]interface{})
-			if ok1 {
-				//fmt.Printf("Mapval1:%v\n", mapval1)
-				fieldValue, found = findFieldValue(mapval1, specfield)
-			}
-			listval, ok2 := value.([]interface{})
-			if ok2 {
-				//fmt.Printf("Listval:%v\n", listval)
-				for _, mapInList := range listval {
-					mapInListMap, ok3 := mapInList.(map[string]interface{})
-					if ok3 {
-						fieldValue, found = findFieldValue(mapInListMap, specfield)
-						if found {
-							break
-						}
-						//return fieldValue, found
-					}
-					stringInListMap, ok4 := mapInList.(string)
-					if ok4 {
-						if key == specfield {
-							//fmt.Printf("Field:%s Value:%s\n", key, stringInListMap)
-							fieldValue = stringInListMap
-							found = true
-							break
-						}
-					}
-				}
+// A value found at a field path, the exact path it was found at, and the
+// namespace, kind and apiVersion given next to it in the same object (e.g.
+// {name: db, namespace: other} or {kind: ClusterRole, name: view}) if any.
+type fieldReference struct {
+	Value string
+	Path string
+	Namespace string
+	Kind string
+	APIVersion string
+}
+
+// Every value at path in content. path is a dot separated field path such as
+// INSTANCE.spec.volumes.persistentVolumeClaim.claimName, or the same in
+// JSONPath form ({.spec.volumes[*].persistentVolumeClaim.claimName}). Lists
+// on the way are expanded whether or not the path gives [*]; [n] picks one
+// element.
+func findFieldReferences(content interface{}, path string) []fieldReference {
+	return walkFieldPath(content, splitFieldPath(path), "")
+}
+
+func splitFieldPath(path string) []string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "{"), "}")
+	path = strings.TrimPrefix(path, "$")
+	path = strings.TrimPrefix(path, "INSTANCE")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, ".")
+}
+
+// Last field name of a path, without an index
+func lastField(path string) string {
+	fields := splitFieldPath(path)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := fields[len(fields)-1]
+	if open := strings.Index(name, "["); open >= 0 {
+		name = name[:open]
+	}
+	return name
+}
+
+func walkFieldPath(value interface{}, fields []string, at string) []fieldReference {
+	refs := make([]fieldReference, 0)
+	if list, ok := value.([]interface{}); ok {
+		for i, item := range list {
+			refs = append(refs, walkFieldPath(item, fields, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return refs
+	}
+	if len(fields) == 0 {
+		if stringval, ok := value.(string); ok {
+			refs = append(refs, fieldReference{Value: stringval, Path: at})
+		}
+		return refs
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return refs
+	}
+	name, index := fields[0], ""
+	if open := strings.Index(name, "["); open >= 0 {
+		name, index = name[:open], strings.Trim(name[open:], "[]")
+	}
+	child, ok := object[name]
+	if !ok {
+		return refs
+	}
+	childAt := name
+	if at != "" {
+		childAt = at + "." + name
+	}
+	if index != "" && index != "*" {
+		list, ok := child.([]interface{})
+		n, err := strconv.Atoi(index)
+		if !ok || err != nil || n < 0 || n >= len(list) {
+			return refs
+		}
+		child, childAt = list[n], fmt.Sprintf("%s[%d]", childAt, n)
+	}
+	found := walkFieldPath(child, fields[1:], childAt)
+	if len(fields) == 1 && name != "namespace" {
+		if namespace, ok := object["namespace"].(string); ok {
+			for i := range found {
+				found[i].Namespace = namespace
 			}
 		}
 	}
-	//fmt.Printf("FieldValue:%s\n", fieldValue)
-
-	// Handle default field values such as Pod.spec.serviceAccountName
-	/*if !found {
-		if specfield == "serviceAccountName" {
-			fieldValue = "default"
-			found = true
+	// The kind of the object itself does not count, and the kind next to a
+	// namespace is that of the object in the namespace
+	if len(fields) == 1 && name != "kind" && name != "namespace" && at != "" {
+		if refKind, ok := object["kind"].(string); ok {
+			refAPIVersion, _ := object["apiVersion"].(string)
+			for i := range found {
+				found[i].Kind = refKind
+				found[i].APIVersion = refAPIVersion
+			}
 		}
-	}*/
-	return fieldValue, found
+	}
+	return append(refs, found...)
 }
 
-func searchSpecPropertyEnv(level int, kind, instance, namespace, rhs, targetKind, targetInstance string) ([]Connection, string) {
+func (q *query) searchSpecPropertyEnv(level int, kind, instance, namespace, rhs, targetKind, targetInstance string) ([]Connection, string, error) {
 	relativesNames := make([]Connection, 0)
 	envNameValue := ""
 	//fmt.Printf("((kind:%s, instance:%s, targetKind:%s, targetInstance:%s))\n", kind, instance, targetKind, targetInstance)
-	dynamicClient, err := getDynamicClient()
-	if err != nil {
-		return relativesNames, envNameValue
-	}
-	lhsResKindPlural, _, lhsResApiVersion, lhsResGroup := getKindAPIDetails(kind)
+	lhsResKindPlural, _, lhsResApiVersion, lhsResGroup := q.registry.getKindAPIDetails(kind)
 	lhsRes := schema.GroupVersionResource{Group: lhsResGroup,
 									   Version: lhsResApiVersion,
 									   Resource: lhsResKindPlural}
-	lhsInstList, err := getObjects(kind, instance, namespace, lhsRes, dynamicClient)
+	lhsInstList, err := q.getObjects(kind, instance, q.queryNamespaces(namespace), lhsRes)
 	if err != nil {
-		return relativesNames, envNameValue
+		return relativesNames, envNameValue, err
 	}
 
-	rhsResKindPlural, _, rhsResApiVersion, rhsResGroup := getKindAPIDetails(targetKind)
+	rhsNamespaces := q.relatedNamespaces(kind, namespace)
+	lhsSources := make([][]envSource, len(lhsInstList))
+	for i, instanceObj := range lhsInstList {
+		lhsSources[i] = podEnvSources(instanceObj)
+		for _, source := range lhsSources[i] {
+			if ref, ok := q.envSourceReference(source, targetKind); ok {
+				rhsNamespaces = addReferencedNamespaces(rhsNamespaces, []fieldReference{ref})
+			}
+		}
+	}
+
+	rhsResKindPlural, _, rhsResApiVersion, rhsResGroup := q.registry.getKindAPIDetails(targetKind)
 	rhsRes := schema.GroupVersionResource{Group: rhsResGroup,
 									   Version: rhsResApiVersion,
 									   Resource: rhsResKindPlural}
-	rhsInstList, err := getObjects(targetKind, targetInstance, namespace, rhsRes, dynamicClient)
+	rhsInstList, err := q.getObjects(targetKind, targetInstance, rhsNamespaces, rhsRes)
 	if err != nil {
-		return relativesNames, envNameValue
+		return relativesNames, envNameValue, err
 	}
 
 	//fmt.Printf("LHSList:%v\n", lhsInstList)
 	//fmt.Printf("RHSList:%v\n", rhsInstList)
-	for _, instanceObj := range lhsInstList {
-		lhsContent := instanceObj.UnstructuredContent()
+	for i, instanceObj := range lhsInstList {
 		lhsName := instanceObj.GetName()
 		//jsonContent, _ := instanceObj.MarshalJSON()
 		//fmt.Printf("JSON Content:%s\n", string(jsonContent))
-		containerList, ok, _ := unstructured.NestedSlice(lhsContent, "spec", "containers")
-		if ok {
-			for _, cont := range containerList {
-				container := cont.(map[string]interface{})
-				envVarList, ok, _ := unstructured.NestedSlice(container,"env")
-				if ok {
-					for _, envVar := range envVarList {
-						envMap := envVar.(map[string]interface{})
-						envName := ""
-						envValue := ""
-						if val, ok := envMap["name"]; ok {
-							envName = val.(string)
-						}
-						//envName := envMap["name"].(string)
-						if val, ok := envMap["value"]; ok {
-							envValue = val.(string)
+		// env, valueFrom and envFrom of containers, initContainers and ephemeralContainers
+		for _, source := range lhsSources[i] {
+			//fmt.Printf("Name:%s, Value:%s", source.name, source.value)
+			ref, ok := q.envSourceReference(source, targetKind)
+			if !ok {
+				continue
+			}
+			for _, unstructuredObj := range rhsInstList {
+				//fmt.Printf(" Service name:%s\n", unstructuredObj.GetName())
+				if rhs == "name" && q.namespaceMatches(ref, instanceObj, targetKind, unstructuredObj) {
+					rhsInstanceName := unstructuredObj.GetName()
+					if ref.Value == rhsInstanceName {
+						var connName, connKind, connNamespace string
+						var peerName, peerKind, peerNamespace string
+						lhsNamespace := q.objectNamespace(kind, instanceObj, namespace)
+						rhsNamespace := q.objectNamespace(targetKind, unstructuredObj, namespace)
+						envNameValue = envSourceDetail(source)
+						if instance == "*" {
+							//fmt.Printf("LHS InstanceName:%s\n", lhsName)
+							connName, connKind, connNamespace = lhsName, kind, lhsNamespace
+							peerName, peerKind, peerNamespace = rhsInstanceName, targetKind, rhsNamespace
+						} else {
+							//fmt.Printf("RHS InstanceName:%s\n", rhsInstanceName)
+							connName, connKind, connNamespace = rhsInstanceName, targetKind, rhsNamespace
+							peerName, peerKind, peerNamespace = lhsName, kind, lhsNamespace
 						}
-						//envValue := envMap["value"].(string)
-						//fmt.Printf("Name:%s, Value:%s", envName, envValue)
-						for _, unstructuredObj := range rhsInstList {
-							//fmt.Printf(" Service name:%s\n", unstructuredObj.GetName())
-							if rhs == "name" {
-								rhsInstanceName := unstructuredObj.GetName()
-								if envValue == rhsInstanceName {
-									var connName, connKind string
-									var peerName, peerKind string
-									if instance == "*" {
-										//fmt.Printf("LHS InstanceName:%s\n", lhsName)
-										connName = lhsName
-										connKind = kind
-										peerName = rhsInstanceName
-										peerKind = targetKind
-									} else {
-										//fmt.Printf("RHS InstanceName:%s\n", rhsInstanceName)
-										connName = rhsInstanceName
-										connKind = targetKind
-										peerName = lhsName
-										peerKind = kind
-										envNameValue = "Name:" + envName + " " + "Value:" + envValue
-									}
-									conn := Connection{
-										Level: level,
-										Name: connName,
-										Kind: connKind,
-										Namespace: namespace,
-										RelationDetails: envNameValue,
-										RelationType: relTypeEnvvariable,
-										Peer: &Connection{
-											Name: peerName,
-											Kind: peerKind,
-											Namespace: namespace,
-										},
-									}
-									connList := make([]Connection,0)
-									connList = append(connList,conn)
-									relativesNames = appendConnections1(relativesNames, connList)
-								}
-							}
+						conn := Connection{
+							Level: level,
+							Name: connName,
+							Kind: connKind,
+							Namespace: connNamespace,
+							RelationDetails: envNameValue,
+							RelationType: relTypeEnvvariable,
+							Peer: &Connection{
+								Name: peerName,
+								Kind: peerKind,
+								Namespace: peerNamespace,
+							},
 						}
+						connList := make([]Connection,0)
+						connList = append(connList,conn)
+						relativesNames = appendConnections1(relativesNames, connList)
 					}
 				}
 			}
 		}
 	}
 	//fmt.Printf("Spec Prop:%v\n", relativesNames)
-	return relativesNames, envNameValue
+	return relativesNames, envNameValue, nil
 }
 
-func getObjects(kind, instance, namespace string, res schema.GroupVersionResource, dynamicClient dynamic.Interface) ([]*unstructured.Unstructured, error) {
+// Objects of kind named instance, or all of them for "*". A single instance
+// is read from the first of namespaces, "*" lists every one of them.
+func (q *query) getObjects(kind, instance string, namespaces []string, res schema.GroupVersionResource) ([]*unstructured.Unstructured, error) {
 	lhsInstList := make([]*unstructured.Unstructured,0)
 	var err error
+	namespace := namespaces[0]
 	if instance == "*" {
 		/*
 		// Non-caching approach to list objects
@@ -857,16 +932,26 @@ func getObjects(kind, instance, namespace string, res schema.GroupVersionResourc
 		// Update (May 13, 2021):
 		// It does look like we are able to discover all relationships.
 		// So turning caching on.
-		lhsInstances, err := getKubeObjectList(kind, namespace, res)
-		if err != nil {
-			return lhsInstList, err
-		}
+		for _, namespace := range namespaces {
+			// Listing all namespaces covers the others
+			if namespace != metav1.NamespaceAll && containsString(namespaces, metav1.NamespaceAll) {
+				continue
+			}
+			lhsInstances, err := q.getKubeObjectList(kind, namespace, res)
+			if err != nil {
+				return lhsInstList, err
+			}
 
-		for _, lhsObj := range lhsInstances.Items {
-			//lhsName := lhsObj.GetName()
-			//fmt.Printf("&&&%s\n", lhsName)
-			lhsObjCopy := lhsObj.DeepCopy()
-			lhsInstList = append(lhsInstList, lhsObjCopy)
+			for _, lhsObj := range lhsInstances.Items {
+				//lhsName := lhsObj.GetName()
+				//fmt.Printf("&&&%s\n", lhsName)
+				lhsObjCopy := lhsObj.DeepCopy()
+				lhsInstList = append(lhsInstList, lhsObjCopy)
+			}
+			// Cluster-scoped kinds are listed once
+			if q.registry.clusterScoped(kind) {
+				break
+			}
 		}
 	} else {
 		/*
@@ -890,9 +975,9 @@ func getObjects(kind, instance, namespace string, res schema.GroupVersionResourc
 		// Update (May 13, 2021):
 		// It does look like we are able to discover all relationships.
 		// So turning caching on.
-		lhsObj, err1 := getKubeObject(kind, instance, namespace, res)
+		lhsObj, err1 := q.getKubeObject(kind, instance, namespace, res)
 		if err1 != nil {
-			return lhsInstList, err
+			return lhsInstList, err1
 		} else {
 			lhsInstList = append(lhsInstList, &lhsObj)
 		}
@@ -900,57 +985,29 @@ func getObjects(kind, instance, namespace string, res schema.GroupVersionResourc
 	return lhsInstList, err
 }
 
-func parseRelationship(relString string) (string, string, string, []string) {
-	targetKindList := make([]string, 0)
-	parts := strings.Split(relString, ",")
-	relType := strings.TrimSpace(parts[0])
-	var lhs, rhs string
-	if relType == relTypeLabel {
-		targetKind := strings.Split(strings.TrimSpace(parts[1]), ":")[1]
-		lhs = strings.Split(strings.TrimSpace(parts[2]), ":")[1]
-		targetKindList = append(targetKindList, targetKind)
-	}
-	if relType == relTypeSpecProperty {
-		targetKindString := strings.Split(strings.TrimSpace(parts[2]), ":")[1]
-		targetKindStringParts := strings.Split(targetKindString, ".")
-		targetKind := targetKindStringParts[0]
-		targetKindList = append(targetKindList, targetKind)
-		rhs = targetKindStringParts[len(targetKindStringParts)-1]
-
-		lhsString := strings.Split(strings.TrimSpace(parts[1]), ":")[1]
-		lhsStringParts := strings.Split(lhsString, ".")
-		lhs = lhsStringParts[len(lhsStringParts)-1]
-	}
-	if relType == relTypeAnnotation {
-		targetKindString := strings.Split(strings.TrimSpace(parts[1]), ":")[1]
-		// resource/annotation-relationship: "on:MysqlCluster; Secret, key:meta.helm.sh/release-name, value:INSTANCE.metadata.name"
-		targetKinds := strings.Split(targetKindString, ";")
-		lhs = strings.Split(strings.TrimSpace(parts[2]), ":")[1]
-		rhsparts := strings.Split(strings.TrimSpace(parts[3]), ":")
-		if len(rhsparts) == 2 { // value:name
-			rhs = rhsparts[1]
-		} else if len(rhsparts) == 3 { //value:[{name:INSTANCE.metadata.name}]
-			rhs = rhsparts[2]
-			rhs = strings.ReplaceAll(rhs, "}", "")
-			rhs = strings.ReplaceAll(rhs, "]", "")
-		}
-		for _, targetKind := range targetKinds {
-			targetKind = strings.TrimSpace(targetKind)
-			targetKindList = append(targetKindList, targetKind)
-		}
-		//fmt.Printf("TargetKindList:%s\n", targetKindList)
+func parseRelationship(relString string) (string, string, string, []string, error) {
+	rel, err := ParseRelationship(relString)
+	if err != nil {
+		return rel.Type, "", "", []string{}, err
 	}
-	if relType == relTypeOwnerReference {
-		targetKind := strings.Split(strings.TrimSpace(parts[1]), ":")[1]
-		targetKindList = append(targetKindList, targetKind)	
+	//fmt.Printf("RelType:%s, lhs:%s, rhs:%s TargetKindList:%s\n", rel.Type, rel.lhs(), rel.rhs(), rel.TargetKinds)
+	return rel.Type, rel.lhs(), rel.rhs(), rel.TargetKinds, nil
+}
+
+// parseRelationship for a relationship declared by kind, with the target
+// kinds given as the kind names used in this query.
+func (r *kindRegistry) parseRelationship(kind, relString string) (string, string, string, []string, error) {
+	relType, lhs, rhs, targetKindList, err := parseRelationship(relString)
+	from := r.groupKind(kind)
+	for i, targetKind := range targetKindList {
+		targetKindList[i] = r.relatedKind(targetKind, from)
 	}
-	//fmt.Printf("RelType:%s, lhs:%s, rhs:%s TargetKindList:%s\n", relType, lhs, rhs, targetKindList)
-	return relType, lhs, rhs, targetKindList
+	return relType, lhs, rhs, targetKindList, err
 }
 
-func getLabels(kind, instance, namespace string) map[string]string {
+func (q *query) getLabels(kind, instance, namespace string) (map[string]string, error) {
 	labelMap := make(map[string]string)
-	resourceKindPlural, _, resourceApiVersion, resourceGroup := getKindAPIDetails(kind)
+	resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(kind)
 	//fmt.Printf("%s, %s, %s\n", resourceGroup, resourceApiVersion, resourceKindPlural)
 	res := schema.GroupVersionResource{Group: resourceGroup,
 									   Version: resourceApiVersion,
@@ -962,100 +1019,297 @@ func getLabels(kind, instance, namespace string) map[string]string {
 																	   		 metav1.GetOptions{})
 	*/
 	// (May 13, 2021): Look up from cache
-	instanceObj, err := getKubeObject(kind, instance, namespace, res)
+	instanceObj, err := q.getKubeObject(kind, instance, namespace, res)
 	if err != nil {
-		fmt.Printf(err.Error())
-		return labelMap
+		return labelMap, err
 	}
 	labelMap = instanceObj.GetLabels()
-	return labelMap
+	if kind == NAMESPACE {
+		labelMap = q.namespaceLabels(instance)
+	}
+	return labelMap, nil
 }
 
-func getSelectorLabels(kind, instance, namespace string) map[string]string {
-	selectorMap := make(map[string]string)
-	var found bool
-
-	dynamicClient, err := getDynamicClient()
-	if err != nil {
-		//fmt.Printf(err.Error())
-		return selectorMap
-	}
-	resourceKindPlural, _, resourceApiVersion, resourceGroup := getKindAPIDetails(kind)
+// The selectors at path (e.g. INSTANCE.spec.selector) of the object, none if
+// it has none.
+func (q *query) getSelectors(kind, instance, namespace, path string) ([]labelSelection, error) {
+	resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(kind)
 	//fmt.Printf("%s, %s, %s\n", resourceGroup, resourceApiVersion, resourceKindPlural)
 	res := schema.GroupVersionResource{Group: resourceGroup,
 									   Version: resourceApiVersion,
 									   Resource: resourceKindPlural}
-	instanceObj, err := dynamicClient.Resource(res).Namespace(namespace).Get(context.TODO(),
-																			 instance,
-																	   		 metav1.GetOptions{})
+	instanceObj, err := q.client.Resource(res).Namespace(q.kindNamespace(kind, namespace)).Get(q.ctx,
+																	   instance,
+																	   metav1.GetOptions{})
 	
-	//instanceObj, err := getKubeObject(kind, instance, namespace, res)
+	//instanceObj, err := q.getKubeObject(kind, instance, namespace, res)
 
 	if err != nil {
 		//fmt.Printf(err.Error())
-		return selectorMap
+		return nil, fromAPIError(err)
 	}
-	content := instanceObj.UnstructuredContent()
-	selectorMap, found, _ = unstructured.NestedStringMap(content, "spec", "selector")
-	if !found {
-		selectorMap, found, _ = unstructured.NestedStringMap(content, "spec", "selector", "matchLabels")
+	return selectorsAt(instanceObj.UnstructuredContent(), path), nil
+}
+
+// A selector found at a selector path. A selector with a namespaceSelector
+// next to it, like a peer of a NetworkPolicy rule, selects objects in the
+// namespaces the namespaceSelector selects instead of in the namespace of
+// the object it is on.
+type labelSelection struct {
+	selector *metav1.LabelSelector
+	namespaceSelector *metav1.LabelSelector
+}
+
+// Selectors are either a plain map of labels, like the selector of a
+// Service, or a LabelSelector with matchLabels and matchExpressions. Lists
+// on the way are expanded, so a path can also hold a list of them, like the
+// clusterRoleSelectors of an aggregated ClusterRole
+// (INSTANCE.aggregationRule.clusterRoleSelectors[*]) or the peers of the
+// rules of a NetworkPolicy (INSTANCE.spec.ingress.from.podSelector[*]),
+// which select what any of them selects. An empty LabelSelector selects
+// everything, but the empty plain map of a Service or ReplicationController
+// selects nothing.
+func selectorsAt(content map[string]interface{}, path string) []labelSelection {
+	fields := splitFieldPath(strings.TrimSuffix(path, "[*]"))
+	selections := collectSelections(content, fields, nil)
+	apiVersion, _ := content["apiVersion"].(string)
+	kind, _ := content["kind"].(string)
+	if apiVersion != "v1" || (kind != SERVICE && kind != RC) {
+		return selections
 	}
-	//fmt.Printf("SelectorMap:%s\n", selectorMap)
-	return selectorMap
+	plainSelections := make([]labelSelection, 0)
+	for _, selection := range selections {
+		if len(selection.selector.MatchLabels) > 0 || len(selection.selector.MatchExpressions) > 0 {
+			plainSelections = append(plainSelections, selection)
+		}
+	}
+	return plainSelections
 }
 
-func searchSelectors(level int, lhsKind string, labelMap map[string]string, rhsKind, rhsInstance, namespace string) ([]Connection, string) {
+func collectSelections(value interface{}, fields []string, parent map[string]interface{}) []labelSelection {
+	selections := make([]labelSelection, 0)
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			selections = append(selections, collectSelections(item, fields, parent)...)
+		}
+		return selections
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return selections
+	}
+	if len(fields) > 0 {
+		child, ok := object[fields[0]]
+		if !ok {
+			return selections
+		}
+		if len(fields) > 1 || fields[0] == "namespaceSelector" {
+			return collectSelections(child, fields[1:], nil)
+		}
+		return collectSelections(child, fields[1:], object)
+	}
+	selector := selectorFromMap(object)
+	if selector == nil {
+		return selections
+	}
+	selection := labelSelection{selector: selector}
+	if namespaceSelectorMap, ok := parent["namespaceSelector"].(map[string]interface{}); ok {
+		selection.namespaceSelector = selectorFromMap(namespaceSelectorMap)
+	}
+	return append(selections, selection)
+}
+
+func selectorFromMap(selectorMap map[string]interface{}) *metav1.LabelSelector {
+	selector := &metav1.LabelSelector{}
+	_, hasMatchLabels := selectorMap["matchLabels"]
+	_, hasMatchExpressions := selectorMap["matchExpressions"]
+	if hasMatchLabels || hasMatchExpressions {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selectorMap, selector); err != nil {
+			return nil
+		}
+	} else {
+		selector.MatchLabels = make(map[string]string)
+		for key, value := range selectorMap {
+			if stringval, ok := value.(string); ok {
+				selector.MatchLabels[key] = stringval
+			}
+		}
+		// Not a map of labels
+		if len(selector.MatchLabels) == 0 && len(selectorMap) > 0 {
+			return nil
+		}
+	}
+	return selector
+}
+
+// Whether selector selects objects with labelMap
+func selectorMatches(selector *metav1.LabelSelector, labelMap map[string]string) bool {
+	if selector == nil {
+		return false
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(labelMap))
+}
+
+// The first of selectors that selects objects with labelMap, nil if none does
+func matchingSelector(selectors []*metav1.LabelSelector, labelMap map[string]string) *metav1.LabelSelector {
+	for _, selector := range selectors {
+		if selectorMatches(selector, labelMap) {
+			return selector
+		}
+	}
+	return nil
+}
+
+// The first of selections that selects an object with labelMap in namespace,
+// for selections found on an object in selectorNamespace.
+func (q *query) matchingSelection(selections []labelSelection, labelMap map[string]string, namespace, selectorNamespace string) (labelSelection, bool) {
+	for _, selection := range selections {
+		if !selectorMatches(selection.selector, labelMap) {
+			continue
+		}
+		if selection.namespaceSelector != nil {
+			if selectorMatches(selection.namespaceSelector, q.namespaceLabels(namespace)) {
+				return selection, true
+			}
+		} else if namespace == "" || selectorNamespace == "" || namespace == selectorNamespace {
+			return selection, true
+		}
+	}
+	return labelSelection{}, false
+}
+
+// Labels of a Namespace, including the kubernetes.io/metadata.name label the
+// API server sets on all of them
+func (q *query) namespaceLabels(name string) map[string]string {
+	labelMap := map[string]string{"kubernetes.io/metadata.name": name}
+	namespaceObj, err := q.getKubeObject(NAMESPACE, name, "", q.kindResource(NAMESPACE))
+	if err != nil {
+		return labelMap
+	}
+	for key, value := range namespaceObj.GetLabels() {
+		labelMap[key] = value
+	}
+	return labelMap
+}
+
+// Namespaces a namespaceSelector selects, of those that exist
+func (q *query) selectedNamespaces(selector *metav1.LabelSelector) ([]string, error) {
+	namespaces := make([]string, 0)
+	namespaceObjs, err := q.getObjects(NAMESPACE, "*", []string{""}, q.kindResource(NAMESPACE))
+	if err != nil {
+		return namespaces, err
+	}
+	for _, namespaceObj := range namespaceObjs {
+		if selectorMatches(selector, q.namespaceLabels(namespaceObj.GetName())) {
+			namespaces = append(namespaces, namespaceObj.GetName())
+		}
+	}
+	return namespaces, nil
+}
+
+// Namespaces objects selected by selections can be in
+func (q *query) selectionNamespaces(selections []labelSelection, kind, namespace string) ([]string, error) {
+	namespaces := q.relatedNamespaces(kind, namespace)
+	for _, selection := range selections {
+		if selection.namespaceSelector == nil {
+			continue
+		}
+		selected, err := q.selectedNamespaces(selection.namespaceSelector)
+		if err != nil {
+			return namespaces, err
+		}
+		for _, ns := range selected {
+			if !containsString(namespaces, ns) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	return namespaces, nil
+}
+
+// matchLabels as key:value, then the expressions, e.g. "app:web tier in (a,b) "
+func selectorDetail(selector *metav1.LabelSelector) string {
+	detail := ""
+	keys := make([]string, 0)
+	for key, _ := range selector.MatchLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		detail = detail + key + ":" + selector.MatchLabels[key] + " "
+	}
+	for _, expr := range selector.MatchExpressions {
+		s, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{expr}})
+		if err == nil {
+			detail = detail + s.String() + " "
+		}
+	}
+	return detail
+}
+
+// With the namespaceSelector of the selection
+func selectionDetail(selection labelSelection) string {
+	detail := selectorDetail(selection.selector)
+	if selection.namespaceSelector != nil {
+		detail = detail + "namespaces:" + strings.TrimSpace(selectorDetail(selection.namespaceSelector)) + " "
+	}
+	return detail
+}
+
+func (q *query) searchSelectors(level int, lhsKind, selectorPath string, labelMap map[string]string, rhsKind, rhsInstance, namespace string) ([]Connection, string, error) {
 	instanceNames := make([]Connection, 0)
 	relDetail := ""
 	/*dynamicClient, err := getDynamicClient()
 	if err != nil {
 		return instanceNames, relDetail
 	}*/
-	resourceKindPlural, _, resourceApiVersion, resourceGroup := getKindAPIDetails(lhsKind)
+	resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(lhsKind)
 	res := schema.GroupVersionResource{Group: resourceGroup,
 									   Version: resourceApiVersion,
 									   Resource: resourceKindPlural}
 
-	list, err := getKubeObjectList(lhsKind, namespace, res)
+	// Objects in other namespaces of the query can select this one with a
+	// namespaceSelector
+	lhsNamespaces := q.relatedNamespaces(rhsKind, namespace)
+	if strings.HasSuffix(selectorPath, "[*]") {
+		lhsNamespaces = q.queryNamespaces(namespace)
+	}
+	list, err := q.getObjects(lhsKind, "*", lhsNamespaces, res)
 		
 	/*list, err := dynamicClient.Resource(res).Namespace(namespace).List(context.TODO(),
 																	   metav1.ListOptions{}) */
 	if err != nil {
-		return instanceNames, relDetail
+		return instanceNames, relDetail, err
 	}
-	for _, unstructuredObj := range list.Items {
-		content := unstructuredObj.UnstructuredContent()
-		selectorMap, found, _ := unstructured.NestedStringMap(content, "spec", "selector")
-		if !found {
-			selectorMap, found, _ = unstructured.NestedStringMap(content, "spec", "selector", "matchLabels")
-		}
-		//fmt.Printf("searchSelectors %s %v\n", unstructuredObj.GetName(), selectorMap)
+	rhsNamespace := q.kindNamespace(rhsKind, namespace)
+	for _, unstructuredObj := range list {
+		selections := selectorsAt(unstructuredObj.UnstructuredContent(), selectorPath)
+		//fmt.Printf("searchSelectors %s %v\n", unstructuredObj.GetName(), selections)
 		//fmt.Printf("searchSelectors %v\n", labelMap)
-		match := false
-		if len(selectorMap) > 0 {
-			match = subsetMatchMaps(selectorMap, labelMap)
-		} 
+		selection, match := q.matchingSelection(selections, labelMap, rhsNamespace, q.objectNamespace(lhsKind, unstructuredObj, namespace))
 		if match {
-			for key, value := range selectorMap {
-				relDetail = relDetail + key + ":" + value + " "
-			}
+			relDetail = selectionDetail(selection)
 			instanceName := Connection{
 				Level: level,
 				Name: unstructuredObj.GetName(),
 				Kind: lhsKind,
-				Namespace: namespace,
+				Namespace: q.objectNamespace(lhsKind, unstructuredObj, namespace),
 				RelationDetails: relDetail,
 				RelationType: relTypeLabel,
 				Peer: &Connection{
 					Name: rhsInstance,
 					Kind: rhsKind,
-					Namespace: namespace,
+					Namespace: rhsNamespace,
 				},
 			}
 			instanceNames = append(instanceNames, instanceName)
 		}
 	}
-	return instanceNames, relDetail
+	return instanceNames, relDetail, nil
 }
 
 func searchNameInLabels(name string, label map[string]string) bool {
@@ -1070,71 +1324,64 @@ func searchNameInLabels(name string, label map[string]string) bool {
 	return false
 }
 
-func searchLabels(level int, sourceKind, sourceInstance string, labelMap map[string]string, targetKind, namespace string) ([]Connection, string) {
+func (q *query) searchLabels(level int, sourceKind, sourceInstance string, selections []labelSelection, targetKind, namespace string) ([]Connection, string, error) {
 	instanceNames := make([]Connection, 0)
 	relDetail := ""
 	/*dynamicClient, err := getDynamicClient()
 	if err != nil {
 		return instanceNames, relDetail
 	}*/
-	resourceKindPlural, _, resourceApiVersion, resourceGroup := getKindAPIDetails(targetKind)
+	resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(targetKind)
 	res := schema.GroupVersionResource{Group: resourceGroup,
 									   Version: resourceApiVersion,
 									   Resource: resourceKindPlural}
 
-	list, err := getKubeObjectList(targetKind, namespace, res)
+	targetNamespaces, err := q.selectionNamespaces(selections, sourceKind, namespace)
+	if err != nil {
+		return instanceNames, relDetail, err
+	}
+	list, err := q.getObjects(targetKind, "*", targetNamespaces, res)
 
 	/*list, err := dynamicClient.Resource(res).Namespace(namespace).List(context.TODO(),
 																	   metav1.ListOptions{})*/
 	if err != nil {
-		return instanceNames, relDetail
+		return instanceNames, relDetail, err
 	}
-	for _, unstructuredObj := range list.Items {
+	sourceNamespace := q.kindNamespace(sourceKind, namespace)
+	for _, unstructuredObj := range list {
 		unstructuredObjLabelMap := unstructuredObj.GetLabels()
+		if targetKind == NAMESPACE {
+			unstructuredObjLabelMap = q.namespaceLabels(unstructuredObj.GetName())
+		}
 		match := false
-		if len(labelMap) > 0 {
-			//fmt.Printf("Service Labels:%v\n",labelMap)
+		var selection labelSelection
+		if len(selections) > 0 {
+			//fmt.Printf("Selector:%v\n",selector)
 			//fmt.Printf("Pod Labels:%v\n",unstructuredObjLabelMap)
-			match = subsetMatchMaps(labelMap, unstructuredObjLabelMap)
+			selection, match = q.matchingSelection(selections, unstructuredObjLabelMap, q.objectNamespace(targetKind, unstructuredObj, namespace), sourceNamespace)
 		} else {
 			match = searchNameInLabels(sourceInstance, unstructuredObjLabelMap)
 		}
 		if match {
-			for key, value := range labelMap {
-				relDetail = relDetail + key + ":" + value + " "
+			if selection.selector != nil {
+				relDetail = selectionDetail(selection)
 			}
 			instanceName := Connection{
 				Level: level,
 				Name: unstructuredObj.GetName(),
 				Kind: targetKind,
-				Namespace: namespace,
+				Namespace: q.objectNamespace(targetKind, unstructuredObj, namespace),
 				RelationDetails: relDetail,
 				RelationType: relTypeLabel,
 				Peer: &Connection{
 					Name: sourceInstance,
 					Kind: sourceKind,
-					Namespace: namespace,
+					Namespace: sourceNamespace,
 				},
 			}
 			instanceNames = append(instanceNames, instanceName)
 		}
 	}
 	//fmt.Printf("Instance Names:%v\n",instanceNames)
-	return instanceNames, relDetail
-}
-
-func subsetMatchMaps(map1, map2 map[string]string) bool {
-	if len(map1) == 0 {
-		return false
-	}
-	for key, element := range map1 {
-		value, found := map2[key]
-		if !found {
-			return false
-		}
-		if value != element {
-			return false
-		}
-	}
-	return true
+	return instanceNames, relDetail, nil
 }
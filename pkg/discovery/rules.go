@@ -0,0 +1,241 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RelationshipRules declare the composition and relationships of a kind
+// without annotating its CRD, e.g. for CRDs of third-party operators:
+//
+//	apiVersion: kubediscovery.cloudark.io/v1
+//	kind: RelationshipRule
+//	metadata:
+//	  name: crossplane-buckets
+//	spec:
+//	  kind: Bucket.s3.aws.crossplane.io
+//	  composition: [Secret]
+//	  relationships:
+//	  - "specproperty, on:INSTANCE.spec.forProvider.roleRef.name, value:Role.metadata.name"
+//	  - "label, on:Pod, value:INSTANCE.spec.selector"
+//
+// They are read from the enabled packs (see packs.go), the cluster (or the
+// manifests) and Options.RuleFiles, and add to what the built-in kinds and
+// the CRD annotations declare.
+type relationshipRule struct {
+	Kind string
+	Composition []string
+	Relationships []string
+	// RelationshipRule/<name>, or <file>: RelationshipRule/<name>
+	Source string
+}
+
+const builtInSource = "built-in"
+
+// KindInfo is a kind with its composition and relationships, and where each
+// of them was declared.
+type KindInfo struct {
+	// Kind.group, or Kind for the core group
+	Kind string
+	Plural string
+	Composition []KindRule
+	Relationships []KindRule
+}
+
+// KindRule is a kind in the composition of another kind, or a relationship
+// declaration.
+type KindRule struct {
+	Value string
+	// built-in, CustomResourceDefinition/<name>, RelationshipRule/<name>,
+	// <file>: RelationshipRule/<name>, <pack> pack v<version>:
+	// RelationshipRule/<name> or KIND_COMPOSITION_FILE
+	Source string
+	// Why a relationship of a RelationshipRule is not used
+	Error string `json:",omitempty"`
+}
+
+// Kinds returns the known kinds with their compositions and relationships,
+// or only the kind given by name.
+func (d *Discoverer) Kinds(ctx context.Context, name string) ([]KindInfo, error) {
+	q := d.newQuery(ctx)
+	err := q.readKindCompositionFile()
+	if err != nil {
+		return nil, err
+	}
+	r := q.registry
+	kinds := make(map[schema.GroupKind]bool)
+	for gk := range r.pluralMap {
+		kinds[gk] = true
+	}
+	for gk := range r.compositionMap {
+		kinds[gk] = true
+	}
+	for gk := range r.relationshipMap {
+		kinds[gk] = true
+	}
+	for gk := range r.ruleErrors {
+		kinds[gk] = true
+	}
+	var only schema.GroupKind
+	if name != "" {
+		only, err = r.resolveKind(name)
+		// Rules can be for kinds whose CRDs are not installed
+		if err != nil {
+			only, err = ruleKind(kinds, name, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	gks := make([]schema.GroupKind, 0)
+	for gk := range kinds {
+		if name == "" || gk == only {
+			gks = append(gks, gk)
+		}
+	}
+	sort.Slice(gks, func(i, j int) bool {
+		return gks[i].String() < gks[j].String()
+	})
+	infos := make([]KindInfo, 0)
+	for _, gk := range gks {
+		info := KindInfo{Kind: gk.String(), Plural: r.pluralMap[gk], Composition: []KindRule{}, Relationships: []KindRule{}}
+		for _, kind := range r.compositionMap[gk] {
+			info.Composition = append(info.Composition, KindRule{Value: strings.TrimSpace(kind), Source: ruleSource(r.compositionSources, gk, kind)})
+		}
+		for _, relString := range r.relationshipMap[gk] {
+			info.Relationships = append(info.Relationships, KindRule{Value: relString, Source: ruleSource(r.relationshipSources, gk, relString)})
+		}
+		decls := make([]string, 0)
+		for decl := range r.ruleErrors[gk] {
+			decls = append(decls, decl)
+		}
+		sort.Strings(decls)
+		for _, decl := range decls {
+			info.Relationships = append(info.Relationships, KindRule{Value: decl, Source: ruleSource(r.relationshipSources, gk, decl),
+				Error: r.ruleErrors[gk][decl]})
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Adds the RelationshipRules of the enabled packs, of the cluster, or of the
+// manifests, and of Options.RuleFiles. Clusters without the RelationshipRule
+// CRD, or that do not let us list them, have none.
+func (q *query) readRelationshipRules() error {
+	rules, err := q.packRules()
+	if err != nil {
+		return err
+	}
+	objects, err := q.getObjects(RELATIONSHIP_RULE, "*", []string{""}, q.kindResource(RELATIONSHIP_RULE))
+	if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUnknownKind) && !errors.Is(err, ErrForbidden) {
+		return err
+	}
+	for _, obj := range objects {
+		rules = append(rules, parseRelationshipRule(obj, RELATIONSHIP_RULE+"/"+obj.GetName()))
+	}
+	for _, path := range q.d.options.RuleFiles {
+		fileObjects, err := readManifestFile(path)
+		if err != nil {
+			return err
+		}
+		for i := range fileObjects {
+			obj := &fileObjects[i]
+			if obj.GetKind() != RELATIONSHIP_RULE {
+				continue
+			}
+			rules = append(rules, parseRelationshipRule(obj, path+": "+RELATIONSHIP_RULE+"/"+obj.GetName()))
+		}
+	}
+	for _, rule := range rules {
+		q.registry.addRelationshipRule(rule)
+	}
+	return nil
+}
+
+// The kind of kinds named name, as Kind or Kind.group, or err
+func ruleKind(kinds map[schema.GroupKind]bool, name string, err error) (schema.GroupKind, error) {
+	matches := make([]schema.GroupKind, 0)
+	for gk := range kinds {
+		if name == gk.Kind || name == gk.String() {
+			matches = append(matches, gk)
+		}
+	}
+	if len(matches) != 1 {
+		return schema.GroupKind{}, err
+	}
+	return matches[0], nil
+}
+
+func parseRelationshipRule(obj *unstructured.Unstructured, source string) relationshipRule {
+	rule := relationshipRule{Source: source}
+	content := obj.UnstructuredContent()
+	rule.Kind, _, _ = unstructured.NestedString(content, "spec", "kind")
+	rule.Composition, _, _ = unstructured.NestedStringSlice(content, "spec", "composition")
+	rule.Relationships, _, _ = unstructured.NestedStringSlice(content, "spec", "relationships")
+	return rule
+}
+
+// Kinds of rules that are not known, such as CRDs that are not installed
+// yet, are kept as written. Relationships that do not parse are only
+// recorded, see Kinds.
+func (r *kindRegistry) addRelationshipRule(rule relationshipRule) {
+	if strings.TrimSpace(rule.Kind) == "" {
+		return
+	}
+	gk, err := r.resolveKind(rule.Kind)
+	if err != nil {
+		gk = schema.ParseGroupKind(strings.TrimSpace(rule.Kind))
+	}
+	for _, kind := range rule.Composition {
+		kind = strings.TrimSpace(kind)
+		if kind == "" || containsTrimmed(r.compositionMap[gk], kind) {
+			continue
+		}
+		r.compositionMap[gk] = append(r.compositionMap[gk], kind)
+		setRuleValue(r.compositionSources, gk, kind, rule.Source)
+	}
+	for _, decl := range rule.Relationships {
+		decl = strings.TrimSpace(decl)
+		if _, err := ParseRelationship(decl); err != nil {
+			setRuleValue(r.ruleErrors, gk, decl, err.Error())
+			setRuleValue(r.relationshipSources, gk, decl, rule.Source)
+			continue
+		}
+		if containsString(r.relationshipMap[gk], decl) {
+			continue
+		}
+		r.relationshipMap[gk] = append(r.relationshipMap[gk], decl)
+		setRuleValue(r.relationshipSources, gk, decl, rule.Source)
+	}
+}
+
+// Records the source, or the error, of a composition or relationship of gk
+func setRuleValue(values map[schema.GroupKind]map[string]string, gk schema.GroupKind, key, value string) {
+	if values[gk] == nil {
+		values[gk] = make(map[string]string)
+	}
+	values[gk][strings.TrimSpace(key)] = value
+}
+
+// Compositions and relationships without a recorded source are built in
+func ruleSource(sources map[schema.GroupKind]map[string]string, gk schema.GroupKind, key string) string {
+	if source, ok := sources[gk][strings.TrimSpace(key)]; ok {
+		return source
+	}
+	return builtInSource
+}
+
+func containsTrimmed(list []string, s string) bool {
+	for _, item := range list {
+		if strings.TrimSpace(item) == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+package discovery
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// Objects of targetKind that the scheduling constraints at path of objects of
+// kind relate them to: the Nodes a Pod spec runs or may run on, or the Pods
+// selected by podAffinity, podAntiAffinity or topologySpreadConstraints.
+func (q *query) searchScheduling(level int, kind, instance, namespace, path, targetKind, targetInstance string) ([]Connection, string, error) {
+	relativesNames := make([]Connection, 0)
+	relDetail := ""
+	lhsInstList, err := q.getObjects(kind, instance, q.queryNamespaces(namespace), q.kindResource(kind))
+	if err != nil {
+		return relativesNames, relDetail, err
+	}
+	// Pod affinity terms can name the namespaces of the Pods they select
+	rhsNamespaces := q.queryNamespaces(namespace)
+	for _, lhsObj := range lhsInstList {
+		for _, term := range podSelectionTerms(lhsObj, path) {
+			termNamespaces, _, _ := unstructured.NestedStringSlice(term, "namespaces")
+			for _, ns := range termNamespaces {
+				if !containsString(rhsNamespaces, ns) {
+					rhsNamespaces = append(rhsNamespaces, ns)
+				}
+			}
+		}
+	}
+	rhsInstList, err := q.getObjects(targetKind, targetInstance, rhsNamespaces, q.kindResource(targetKind))
+	if err != nil {
+		return relativesNames, relDetail, err
+	}
+	for _, lhsObj := range lhsInstList {
+		lhsNamespace := q.objectNamespace(kind, lhsObj, namespace)
+		for _, rhsObj := range rhsInstList {
+			rhsNamespace := q.objectNamespace(targetKind, rhsObj, namespace)
+			if kind == targetKind && lhsObj.GetName() == rhsObj.GetName() && lhsNamespace == rhsNamespace {
+				continue
+			}
+			detail, match := q.schedulingMatch(lhsObj, lhsNamespace, path, rhsObj, rhsNamespace)
+			if !match {
+				continue
+			}
+			relDetail = detail
+			var connName, connKind, connNamespace string
+			var peerName, peerKind, peerNamespace string
+			if instance == "*" {
+				connName, connKind, connNamespace = lhsObj.GetName(), kind, lhsNamespace
+				peerName, peerKind, peerNamespace = rhsObj.GetName(), targetKind, rhsNamespace
+			} else {
+				connName, connKind, connNamespace = rhsObj.GetName(), targetKind, rhsNamespace
+				peerName, peerKind, peerNamespace = lhsObj.GetName(), kind, lhsNamespace
+			}
+			conn := Connection{
+				Level: level,
+				Name: connName,
+				Kind: connKind,
+				Namespace: connNamespace,
+				RelationDetails: detail,
+				RelationType: relTypeScheduling,
+				Peer: &Connection{
+					Name: peerName,
+					Kind: peerKind,
+					Namespace: peerNamespace,
+				},
+			}
+			relativesNames = append(relativesNames, conn)
+		}
+	}
+	return relativesNames, relDetail, nil
+}
+
+// Whether the constraints at path of pod relate it to obj, and how
+func (q *query) schedulingMatch(pod *unstructured.Unstructured, podNamespace, path string, obj *unstructured.Unstructured, objNamespace string) (string, bool) {
+	constraint := lastField(path)
+	switch constraint {
+	case "podAffinity", "podAntiAffinity", "topologySpreadConstraints":
+		for _, term := range podSelectionTerms(pod, path) {
+			selectorMap, ok := term["labelSelector"].(map[string]interface{})
+			if !ok || !selectorMatches(selectorFromMap(selectorMap), obj.GetLabels()) {
+				continue
+			}
+			if !q.termNamespaceMatches(term, podNamespace, objNamespace) {
+				continue
+			}
+			topologyKey, _, _ := unstructured.NestedString(term, "topologyKey")
+			return constraint + " " + selectorDetail(selectorFromMap(selectorMap)) + "topologyKey:" + topologyKey, true
+		}
+		return "", false
+	}
+	podSpec, _, _ := unstructured.NestedMap(pod.UnstructuredContent(), splitFieldPath(path)...)
+	return nodeSchedulingMatch(podSpec, obj)
+}
+
+// The terms at path that select Pods: the required and preferred terms of
+// podAffinity and podAntiAffinity, or topologySpreadConstraints, which only
+// select Pods in the namespace of the Pod.
+func podSelectionTerms(pod *unstructured.Unstructured, path string) []map[string]interface{} {
+	terms := make([]map[string]interface{}, 0)
+	value, found, _ := unstructured.NestedFieldNoCopy(pod.UnstructuredContent(), splitFieldPath(path)...)
+	if !found {
+		return terms
+	}
+	if constraints, ok := value.([]interface{}); ok {
+		for _, item := range constraints {
+			if constraint, ok := item.(map[string]interface{}); ok {
+				terms = append(terms, constraint)
+			}
+		}
+		return terms
+	}
+	affinity, ok := value.(map[string]interface{})
+	if !ok {
+		return terms
+	}
+	required, _, _ := unstructured.NestedSlice(affinity, "requiredDuringSchedulingIgnoredDuringExecution")
+	for _, item := range required {
+		if term, ok := item.(map[string]interface{}); ok {
+			terms = append(terms, term)
+		}
+	}
+	preferred, _, _ := unstructured.NestedSlice(affinity, "preferredDuringSchedulingIgnoredDuringExecution")
+	for _, item := range preferred {
+		weighted, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if term, ok := weighted["podAffinityTerm"].(map[string]interface{}); ok {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Terms select Pods in the namespaces they list or select, or else in the
+// namespace of the Pod
+func (q *query) termNamespaceMatches(term map[string]interface{}, podNamespace, namespace string) bool {
+	namespaces, _, _ := unstructured.NestedStringSlice(term, "namespaces")
+	namespaceSelectorMap, hasNamespaceSelector := term["namespaceSelector"].(map[string]interface{})
+	if len(namespaces) == 0 && !hasNamespaceSelector {
+		return namespace == podNamespace
+	}
+	if containsString(namespaces, namespace) {
+		return true
+	}
+	return hasNamespaceSelector && selectorMatches(selectorFromMap(namespaceSelectorMap), q.namespaceLabels(namespace))
+}
+
+// A Pod runs on the Node of its nodeName, and may run on the Nodes that match
+// its nodeSelector and required nodeAffinity and whose NoSchedule and
+// NoExecute taints it tolerates.
+func nodeSchedulingMatch(podSpec map[string]interface{}, node *unstructured.Unstructured) (string, bool) {
+	if podSpec == nil {
+		return "", false
+	}
+	if nodeName, _ := podSpec["nodeName"].(string); nodeName == node.GetName() {
+		return "nodeName:" + nodeName, true
+	}
+	constraints := make([]string, 0)
+	nodeLabels := node.GetLabels()
+	nodeSelector, _, _ := unstructured.NestedStringMap(podSpec, "nodeSelector")
+	for key, value := range nodeSelector {
+		if nodeValue, ok := nodeLabels[key]; !ok || nodeValue != value {
+			return "", false
+		}
+	}
+	if len(nodeSelector) > 0 {
+		constraints = append(constraints, "nodeSelector")
+	}
+	terms, found, _ := unstructured.NestedSlice(podSpec, "affinity", "nodeAffinity", "requiredDuringSchedulingIgnoredDuringExecution", "nodeSelectorTerms")
+	if found {
+		// Terms are ORed
+		match := false
+		for _, item := range terms {
+			term := corev1.NodeSelectorTerm{}
+			termMap, ok := item.(map[string]interface{})
+			if ok && runtime.DefaultUnstructuredConverter.FromUnstructured(termMap, &term) == nil && nodeSelectorTermMatches(term, node) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return "", false
+		}
+		constraints = append(constraints, "nodeAffinity")
+	}
+	tolerations := make([]corev1.Toleration, 0)
+	tolerationList, _, _ := unstructured.NestedSlice(podSpec, "tolerations")
+	for _, item := range tolerationList {
+		toleration := corev1.Toleration{}
+		tolerationMap, ok := item.(map[string]interface{})
+		if ok && runtime.DefaultUnstructuredConverter.FromUnstructured(tolerationMap, &toleration) == nil {
+			tolerations = append(tolerations, toleration)
+		}
+	}
+	taints, _, _ := unstructured.NestedSlice(node.UnstructuredContent(), "spec", "taints")
+	tolerated := false
+	for _, item := range taints {
+		taint := corev1.Taint{}
+		taintMap, ok := item.(map[string]interface{})
+		if !ok || runtime.DefaultUnstructuredConverter.FromUnstructured(taintMap, &taint) != nil {
+			continue
+		}
+		if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+			continue
+		}
+		if !toleratesTaint(tolerations, &taint) {
+			return "", false
+		}
+		tolerated = true
+	}
+	if tolerated {
+		constraints = append(constraints, "tolerations")
+	}
+	if len(constraints) == 0 {
+		return "eligible", true
+	}
+	return "eligible by " + strings.Join(constraints, ", "), true
+}
+
+func toleratesTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchExpressions are on the labels of the Node, matchFields on its
+// metadata.name. A term with neither matches no Node.
+func nodeSelectorTermMatches(term corev1.NodeSelectorTerm, node *unstructured.Unstructured) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	for _, expr := range term.MatchExpressions {
+		if !nodeSelectorRequirementMatches(expr, node.GetLabels()) {
+			return false
+		}
+	}
+	for _, expr := range term.MatchFields {
+		if !nodeSelectorRequirementMatches(expr, map[string]string{"metadata.name": node.GetName()}) {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeSelectorRequirementMatches(expr corev1.NodeSelectorRequirement, labelMap map[string]string) bool {
+	operators := map[corev1.NodeSelectorOperator]selection.Operator{
+		corev1.NodeSelectorOpIn: selection.In,
+		corev1.NodeSelectorOpNotIn: selection.NotIn,
+		corev1.NodeSelectorOpExists: selection.Exists,
+		corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+		corev1.NodeSelectorOpGt: selection.GreaterThan,
+		corev1.NodeSelectorOpLt: selection.LessThan,
+	}
+	operator, ok := operators[expr.Operator]
+	if !ok {
+		return false
+	}
+	requirement, err := labels.NewRequirement(expr.Key, operator, expr.Values)
+	if err != nil {
+		return false
+	}
+	return requirement.Matches(labels.Set(labelMap))
+}
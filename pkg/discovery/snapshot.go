@@ -0,0 +1,288 @@
+package discovery
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Bumped whenever the archive layout changes in a way older readers cannot handle.
+const SnapshotFormatVersion = 1
+
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+const (
+	snapshotManifestFile = "snapshot.json"
+	snapshotCRDFile      = "crds.yaml"
+	snapshotObjectsDir   = "objects"
+)
+
+// Stored as snapshot.json at the root of the archive. Kinds counts the
+// objects of each kind that has any; Skipped has the kinds that could not be
+// listed, with the error.
+type SnapshotManifest struct {
+	FormatVersion int               `json:"formatVersion"`
+	Created       string            `json:"created"`
+	Namespace     string            `json:"namespace"`
+	Kinds         map[string]int    `json:"kinds"`
+	Skipped       map[string]string `json:"skipped,omitempty"`
+}
+
+// WriteSnapshot lists every built-in kind and the kinds of all CRDs in the
+// given namespace, or in all namespaces if it is empty, and
+// writes them together with the CRDs to a gzipped tar archive at outPath.
+// Cluster-scoped kinds are captured in full. Secret values are redacted.
+func (d *Discoverer) WriteSnapshot(ctx context.Context, namespace, outPath string) (SnapshotManifest, error) {
+	manifest := SnapshotManifest{
+		FormatVersion: SnapshotFormatVersion,
+		Created:       time.Now().UTC().Format(time.RFC3339),
+		Namespace:     namespace,
+		Kinds:         make(map[string]int),
+		Skipped:       make(map[string]string),
+	}
+
+	q := d.newQuery(ctx)
+	err := q.readKindCompositionFile()
+	if err != nil {
+		return manifest, err
+	}
+
+	files := make(map[string][]byte)
+
+	crdList, err := d.listCRDs(ctx)
+	if err != nil {
+		return manifest, err
+	}
+	crds := &unstructured.UnstructuredList{}
+	for _, crd := range crdList {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(crd)
+		if err != nil {
+			return manifest, err
+		}
+		crdObj := unstructured.Unstructured{Object: content}
+		crdObj.SetAPIVersion("apiextensions.k8s.io/v1")
+		crdObj.SetKind("CustomResourceDefinition")
+		crds.Items = append(crds.Items, crdObj)
+	}
+	files[snapshotCRDFile], err = marshalSnapshotList(crds)
+	if err != nil {
+		return manifest, err
+	}
+
+	kinds := make([]string, 0)
+	for gk := range q.registry.pluralMap {
+		kinds = append(kinds, q.registry.kindName(gk))
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(kind)
+		if resourceKindPlural == "" || resourceApiVersion == "" {
+			continue
+		}
+		res := schema.GroupVersionResource{Group: resourceGroup,
+			Version:  resourceApiVersion,
+			Resource: resourceKindPlural}
+		list, err := d.client.Resource(res).Namespace(q.kindNamespace(kind, namespace)).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			manifest.Skipped[kind] = err.Error()
+			continue
+		}
+		if len(list.Items) == 0 {
+			continue
+		}
+		for i := range list.Items {
+			item := &list.Items[i]
+			// List items do not always carry their type.
+			item.SetAPIVersion(res.GroupVersion().String())
+			item.SetKind(q.registry.groupKind(kind).Kind)
+			unstructured.RemoveNestedField(item.Object, "metadata", "managedFields")
+			if kind == SECRET {
+				redactSecret(item)
+			}
+		}
+		fileName := resourceKindPlural
+		if resourceGroup != "" {
+			fileName = fileName + "." + resourceGroup
+		}
+		files[path.Join(snapshotObjectsDir, fileName+".yaml")], err = marshalSnapshotList(list)
+		if err != nil {
+			return manifest, err
+		}
+		manifest.Kinds[kind] = len(list.Items)
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	// Written next to outPath and renamed into place, so that a failed
+	// snapshot never leaves a truncated archive behind
+	out, err := ioutil.TempFile(filepath.Dir(outPath), ".snapshot-*.tar.gz")
+	if err != nil {
+		return manifest, err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	gzipWriter := gzip.NewWriter(out)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	names := make([]string, 0)
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// The manifest goes first so that readers can reject unknown versions early.
+	err = writeSnapshotFile(tarWriter, snapshotManifestFile, manifestBytes)
+	for _, name := range names {
+		if err != nil {
+			break
+		}
+		err = writeSnapshotFile(tarWriter, name, files[name])
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return manifest, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return manifest, err
+	}
+	if err := out.Close(); err != nil {
+		return manifest, err
+	}
+	if err := os.Chmod(out.Name(), 0644); err != nil {
+		return manifest, err
+	}
+	return manifest, os.Rename(out.Name(), outPath)
+}
+
+// LoadSnapshot reads the objects of an archive written by WriteSnapshot.
+func LoadSnapshot(snapshotPath string) (*Manifests, SnapshotManifest, error) {
+	var manifest SnapshotManifest
+	in, err := os.Open(snapshotPath)
+	if err != nil {
+		return nil, manifest, err
+	}
+	defer in.Close()
+	gzipReader, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, manifest, fmt.Errorf("%s is not a snapshot archive: %s", snapshotPath, err.Error())
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	foundManifest := false
+	objects := make([]unstructured.Unstructured, 0)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, manifest, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, manifest, err
+		}
+		if header.Name == snapshotManifestFile {
+			err = json.Unmarshal(content, &manifest)
+			if err != nil {
+				return nil, manifest, fmt.Errorf("%s: %s", snapshotManifestFile, err.Error())
+			}
+			if manifest.FormatVersion > SnapshotFormatVersion {
+				return nil, manifest, fmt.Errorf("snapshot format version %d is newer than supported version %d",
+					manifest.FormatVersion, SnapshotFormatVersion)
+			}
+			foundManifest = true
+			continue
+		}
+		if !strings.HasSuffix(header.Name, ".yaml") {
+			continue
+		}
+		fileObjects, err := readManifests(bytes.NewReader(content))
+		if err != nil {
+			return nil, manifest, fmt.Errorf("%s: %s", header.Name, err.Error())
+		}
+		objects = append(objects, fileObjects...)
+	}
+	if !foundManifest {
+		return nil, manifest, fmt.Errorf("%s is not a snapshot archive: %s is missing", snapshotPath, snapshotManifestFile)
+	}
+	manifests, err := newManifests(objects)
+	return manifests, manifest, err
+}
+
+// Keys are kept so that relationships to individual entries can still be
+// shown. Annotations that hold the whole Secret, such as the
+// last-applied-configuration of kubectl apply, are dropped.
+func redactSecret(secret *unstructured.Unstructured) {
+	annotations := secret.GetAnnotations()
+	for key, value := range annotations {
+		body := make(map[string]interface{})
+		if key == lastAppliedAnnotation || (json.Unmarshal([]byte(value), &body) == nil && secretBody(body)) {
+			delete(annotations, key)
+		}
+	}
+	if annotations != nil {
+		secret.SetAnnotations(annotations)
+	}
+	for _, field := range []string{"data", "stringData"} {
+		data, found, _ := unstructured.NestedMap(secret.Object, field)
+		if !found {
+			continue
+		}
+		for key := range data {
+			data[key] = ""
+		}
+		_ = unstructured.SetNestedMap(secret.Object, data, field)
+	}
+}
+
+func secretBody(body map[string]interface{}) bool {
+	_, hasData := body["data"]
+	_, hasStringData := body["stringData"]
+	return hasData || hasStringData
+}
+
+func marshalSnapshotList(list *unstructured.UnstructuredList) ([]byte, error) {
+	list.SetAPIVersion("v1")
+	list.SetKind("List")
+	content := list.UnstructuredContent()
+	return yaml.Marshal(content)
+}
+
+func writeSnapshotFile(tarWriter *tar.Writer, name string, content []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tarWriter.Write(content)
+	return err
+}
@@ -0,0 +1,235 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// StorageNode is an object in the storage lineage of a workload, with the
+// objects its storage comes from as Children.
+type StorageNode struct {
+	Kind string
+	Name string
+	Namespace string
+	// e.g. capacity, accessModes, reclaimPolicy and phase
+	Attributes map[string]string `json:",omitempty"`
+	Children []StorageNode
+}
+
+// Storage returns the storage lineage of ref: the PersistentVolumeClaims of
+// a workload, the PersistentVolumes bound to them, their StorageClasses and
+// CSI drivers, the snapshots of the claims and the VolumeAttachments of the
+// volumes to Nodes. ref can also be any object along the way, such as a
+// PersistentVolume. Objects already in the lineage are not repeated.
+func (d *Discoverer) Storage(ctx context.Context, ref ObjectRef) (StorageNode, error) {
+	q := d.newQuery(ctx)
+	ref, err := q.resolveRef(ref)
+	if err != nil {
+		return StorageNode{}, err
+	}
+	err = q.checkExistence(ref.Kind, ref.Name, ref.Namespace)
+	if err != nil {
+		return StorageNode{}, err
+	}
+	obj, err := q.getKubeObject(ref.Kind, ref.Name, ref.Namespace, q.kindResource(ref.Kind))
+	if err != nil {
+		return StorageNode{}, err
+	}
+	return q.storageNode(ref.Kind, &obj, ref.Namespace, make(map[string]bool)), nil
+}
+
+func (q *query) storageNode(kind string, obj *unstructured.Unstructured, namespace string, seen map[string]bool) StorageNode {
+	node := StorageNode{
+		Kind: kind,
+		Name: obj.GetName(),
+		Namespace: q.objectNamespace(kind, obj, namespace),
+		Children: []StorageNode{},
+	}
+	seen[node.Kind+"/"+node.Namespace+"/"+node.Name] = true
+	attributes := storageAttributes(q.registry.groupKind(kind), obj)
+	if len(attributes) > 0 {
+		node.Attributes = attributes
+	}
+	for _, ref := range q.storageChildren(kind, obj, node.Namespace) {
+		childNamespace := ""
+		if !q.registry.clusterScoped(ref.Kind) {
+			childNamespace = node.Namespace
+		}
+		if seen[ref.Kind+"/"+childNamespace+"/"+ref.Name] {
+			continue
+		}
+		// e.g. in-tree provisioners have no CSIDriver
+		child, err := q.getKubeObject(ref.Kind, ref.Name, childNamespace, q.kindResource(ref.Kind))
+		if err != nil {
+			continue
+		}
+		node.Children = append(node.Children, q.storageNode(ref.Kind, &child, childNamespace, seen))
+	}
+	return node
+}
+
+// Objects the storage of obj comes from. Objects that refer to obj, such as
+// the VolumeSnapshots of a claim, are listed in namespace.
+func (q *query) storageChildren(kind string, obj *unstructured.Unstructured, namespace string) []ObjectRef {
+	refs := make([]ObjectRef, 0)
+	content := obj.UnstructuredContent()
+	add := func(gk schema.GroupKind, fields ...string) {
+		name, _, _ := unstructured.NestedString(content, fields...)
+		if name != "" {
+			refs = append(refs, ObjectRef{Kind: q.registry.kindName(gk), Name: name})
+		}
+	}
+	// Objects of gk whose field at path is the name of obj
+	addReferring := func(gk schema.GroupKind, path string) {
+		refKind := q.registry.kindName(gk)
+		objects, err := q.getObjects(refKind, "*", []string{namespace}, q.kindResource(refKind))
+		if err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrUnknownKind) {
+			return
+		}
+		names := make([]string, 0)
+		for _, object := range objects {
+			for _, fieldRef := range findFieldReferences(object.UnstructuredContent(), path) {
+				if fieldRef.Value == obj.GetName() {
+					names = append(names, object.GetName())
+					break
+				}
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			refs = append(refs, ObjectRef{Kind: refKind, Name: name})
+		}
+	}
+	pvc := schema.GroupKind{Kind: PVCLAIM}
+	pv := schema.GroupKind{Kind: PV}
+	storageClass := schema.GroupKind{Group: "storage.k8s.io", Kind: STORAGE_CLASS}
+	csiDriver := schema.GroupKind{Group: "storage.k8s.io", Kind: CSI_DRIVER}
+	volumeAttachment := schema.GroupKind{Group: "storage.k8s.io", Kind: VOLUME_ATTACHMENT}
+	volumeSnapshot := schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT}
+	volumeSnapshotContent := schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT_CONTENT}
+	volumeSnapshotClass := schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT_CLASS}
+	switch q.registry.groupKind(kind) {
+	case pvc:
+		add(pv, "spec", "volumeName")
+		add(storageClass, "spec", "storageClassName")
+		addReferring(volumeSnapshot, "spec.source.persistentVolumeClaimName")
+	case pv:
+		add(storageClass, "spec", "storageClassName")
+		add(csiDriver, "spec", "csi", "driver")
+		addReferring(volumeAttachment, "spec.source.persistentVolumeName")
+	case storageClass:
+		add(csiDriver, "provisioner")
+	case volumeAttachment:
+		add(schema.GroupKind{Kind: NODE}, "spec", "nodeName")
+	case volumeSnapshot:
+		add(volumeSnapshotContent, "status", "boundVolumeSnapshotContentName")
+		add(volumeSnapshotClass, "spec", "volumeSnapshotClassName")
+	case volumeSnapshotContent:
+		add(volumeSnapshotClass, "spec", "volumeSnapshotClassName")
+		add(csiDriver, "spec", "driver")
+	case volumeSnapshotClass:
+		add(csiDriver, "driver")
+	default:
+		for _, name := range q.podClaimNames(kind, obj, namespace) {
+			refs = append(refs, ObjectRef{Kind: q.registry.kindName(pvc), Name: name})
+		}
+	}
+	return refs
+}
+
+// Claims of the Pods obj runs: those of its persistentVolumeClaim and
+// ephemeral volumes and, for a StatefulSet, those made from its
+// volumeClaimTemplates, named <template>-<statefulset>-<ordinal>.
+func (q *query) podClaimNames(kind string, obj *unstructured.Unstructured, namespace string) []string {
+	names := make([]string, 0)
+	for _, path := range [][]string{{"spec"}, {"spec", "template", "spec"}, {"spec", "jobTemplate", "spec", "template", "spec"}} {
+		volumes, _, _ := unstructured.NestedSlice(obj.UnstructuredContent(), append(path, "volumes")...)
+		for _, item := range volumes {
+			volume, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			claimName, _, _ := unstructured.NestedString(volume, "persistentVolumeClaim", "claimName")
+			if claimName != "" && !containsString(names, claimName) {
+				names = append(names, claimName)
+			}
+			// Only a Pod knows the name of its ephemeral claims
+			_, ephemeral := volume["ephemeral"]
+			volumeName, _ := volume["name"].(string)
+			if ephemeral && len(path) == 1 && kind == POD {
+				names = append(names, obj.GetName()+"-"+volumeName)
+			}
+		}
+	}
+	templates, _, _ := unstructured.NestedSlice(obj.UnstructuredContent(), "spec", "volumeClaimTemplates")
+	if len(templates) == 0 || q.registry.groupKind(kind) != (schema.GroupKind{Group: "apps", Kind: STATEFULSET}) {
+		return names
+	}
+	claims, err := q.getObjects(PVCLAIM, "*", []string{namespace}, q.kindResource(PVCLAIM))
+	if err != nil {
+		return names
+	}
+	claimNames := make([]string, 0)
+	for _, item := range templates {
+		template, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		templateName, _, _ := unstructured.NestedString(template, "metadata", "name")
+		claimPattern := regexp.MustCompile("^" + regexp.QuoteMeta(templateName+"-"+obj.GetName()+"-") + "[0-9]+$")
+		for _, claim := range claims {
+			if claimPattern.MatchString(claim.GetName()) && !containsString(names, claim.GetName()) {
+				claimNames = append(claimNames, claim.GetName())
+			}
+		}
+	}
+	sort.Strings(claimNames)
+	return append(names, claimNames...)
+}
+
+// Attributes of the objects in a storage lineage
+func storageAttributes(gk schema.GroupKind, obj *unstructured.Unstructured) map[string]string {
+	attributes := make(map[string]string)
+	content := obj.UnstructuredContent()
+	switch gk {
+	case schema.GroupKind{Kind: PVCLAIM}:
+		// What was asked for until the claim is bound
+		setAttribute(attributes, "capacity", content, "spec", "resources", "requests", "storage")
+		setAttribute(attributes, "accessModes", content, "spec", "accessModes")
+		setAttribute(attributes, "capacity", content, "status", "capacity", "storage")
+		setAttribute(attributes, "accessModes", content, "status", "accessModes")
+		setAttribute(attributes, "phase", content, "status", "phase")
+	case schema.GroupKind{Kind: PV}:
+		setAttribute(attributes, "capacity", content, "spec", "capacity", "storage")
+		setAttribute(attributes, "accessModes", content, "spec", "accessModes")
+		setAttribute(attributes, "reclaimPolicy", content, "spec", "persistentVolumeReclaimPolicy")
+		setAttribute(attributes, "phase", content, "status", "phase")
+	case schema.GroupKind{Group: "storage.k8s.io", Kind: STORAGE_CLASS}:
+		attributes["reclaimPolicy"] = "Delete"
+		attributes["volumeBindingMode"] = "Immediate"
+		setAttribute(attributes, "provisioner", content, "provisioner")
+		setAttribute(attributes, "reclaimPolicy", content, "reclaimPolicy")
+		setAttribute(attributes, "volumeBindingMode", content, "volumeBindingMode")
+		setAttribute(attributes, "allowVolumeExpansion", content, "allowVolumeExpansion")
+	case schema.GroupKind{Group: "storage.k8s.io", Kind: CSI_DRIVER}:
+		attributes["attachRequired"] = "true"
+		setAttribute(attributes, "attachRequired", content, "spec", "attachRequired")
+	case schema.GroupKind{Group: "storage.k8s.io", Kind: VOLUME_ATTACHMENT}:
+		setAttribute(attributes, "attached", content, "status", "attached")
+	case schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT}:
+		setAttribute(attributes, "readyToUse", content, "status", "readyToUse")
+		setAttribute(attributes, "restoreSize", content, "status", "restoreSize")
+	case schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT_CONTENT}:
+		setAttribute(attributes, "deletionPolicy", content, "spec", "deletionPolicy")
+		setAttribute(attributes, "readyToUse", content, "status", "readyToUse")
+		setAttribute(attributes, "restoreSize", content, "status", "restoreSize")
+	case schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT_CLASS}:
+		setAttribute(attributes, "deletionPolicy", content, "deletionPolicy")
+	}
+	return attributes
+}
@@ -1,8 +1,10 @@
 package discovery
 
 import (
+	"sort"
 	"sync"
 	"strings"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -12,6 +14,8 @@ type composition struct {
 	Plural      string   `yaml:"plural"`
 	Endpoint    string   `yaml:"endpoint"`
 	Composition []string `yaml:"composition"`
+	// "Cluster" for cluster-scoped kinds
+	Scope       string   `yaml:"scope"`
 }
 
 // Used for Final output
@@ -21,6 +25,8 @@ type Composition struct {
 	Name      string
 	Namespace string
 	Status    string
+	// Run history of Jobs and CronJobs, replicas of what can be scaled
+	Attributes map[string]string `json:",omitempty"`
 	Children  []Composition
 }
 
@@ -32,6 +38,7 @@ type MetaDataAndOwnerReferences struct {
 	OwnerReferenceName       string
 	OwnerReferenceKind       string
 	OwnerReferenceAPIVersion string
+	Attributes               map[string]string
 }
 
 // Used for intermediate storage -- probably can be combined/merged with
@@ -48,6 +55,7 @@ type Compositions struct {
 	Name            string
 	Namespace       string
 	Status          string
+	Attributes      map[string]string
 	CompositionTree *[]CompositionTreeNode
 }
 
@@ -68,6 +76,10 @@ type Connection struct {
 	OwnerKind 		string
 	OwnerName    	string
 	Peer           *Connection
+	// Replicas of autoscalers and what they scale, budget of PodDisruptionBudgets
+	Attributes     map[string]string
+	// Related through a field shaped like an object reference, see Options.InferReferences
+	Inferred       bool
 }
 
 type ConnectionOutput struct {
@@ -80,6 +92,8 @@ type ConnectionOutput struct {
 	PeerNamespace	string
 	RelationType	string
 	RelationDetails string
+	Attributes      map[string]string `json:",omitempty"`
+	Inferred        bool `json:",omitempty"`
 }
 
 type KubeObjectCacheEntry struct {
@@ -99,18 +113,20 @@ var (
 	LABEL_REL_ANNOTATION string
 	SPECPROPERTY_REL_ANNOTATION string
 
-	TotalClusterCompositions ClusterCompositions
-	TotalClusterConnections []Connection
-
-	KindPluralMap  map[string]string
-	kindVersionMap map[string]string
-	kindGroupMap map[string]string
-	compositionMap map[string][]string
-	relationshipMap map[string][]string
-	crdcompositionMap map[string][]string
-
-	kubeObjectListCache map[KubeObjectCacheEntry]interface{}
-	kubeObjectCache map[KubeObjectCacheEntry]interface{}
+	// Built-in kinds. These are only written in init(); every query
+	// works on its own copy (see kindRegistry).
+	KindPluralMap  map[schema.GroupKind]string
+	kindVersionMap map[schema.GroupKind]string
+	compositionMap map[schema.GroupKind][]string
+	relationshipMap map[schema.GroupKind][]string
+	// Short names accepted on the command line, as in kubectl
+	kindShortNames map[schema.GroupKind][]string
+	// Paths of the desired and current replicas of kinds that can be scaled,
+	// as in the scale subresource of a CRD
+	scaleReplicasMap map[schema.GroupKind][]string
+	// Kinds whose objects do not live in a namespace. Only used when there is
+	// no API server to ask.
+	clusterScopedKinds map[schema.GroupKind]bool
 
 	REPLICA_SET  string
 	DEPLOYMENT   string
@@ -128,25 +144,42 @@ var (
 	RC           string
 	PDB 		 string
 	NAMESPACE    string
+	NODE         string
+	STORAGE_CLASS string
+	CLUSTER_ROLE string
+	CLUSTER_ROLE_BINDING string
+	CSI_DRIVER string
+	ROLE string
+	ROLE_BINDING string
+	NETWORK_POLICY string
+	HPA string
+	VPA string
+	JOB string
+	CRON_JOB string
+	VALIDATING_WEBHOOK_CONFIGURATION string
+	MUTATING_WEBHOOK_CONFIGURATION string
+	CRD string
+	API_SERVICE string
+	INGRESS_CLASS string
+	GATEWAY_CLASS string
+	GATEWAY string
+	HTTP_ROUTE string
+	GRPC_ROUTE string
+	REFERENCE_GRANT string
+	VOLUME_SNAPSHOT string
+	VOLUME_SNAPSHOT_CONTENT string
+	VOLUME_SNAPSHOT_CLASS string
+	VOLUME_ATTACHMENT string
+	RELATIONSHIP_RULE string
 
 	relTypeLabel string
 	relTypeSpecProperty string
 	relTypeEnvvariable string
 	relTypeAnnotation string
 	relTypeOwnerReference string
+	relTypeScheduling string
 
-	green, red, yellow, purple, cyan, reset string
-
-	// Set to inputs given to connections
-	OrigKind, OrigName, OrigNamespace string
-	OrigLevel int
-	OutputFormat string
-	RelsToIgnore string
-
-	NamespaceToSearch string
-	OriginalInputNamespace string
-	OriginalInputKind string
-	OriginalInputInstance string
+	green, red, yellow, blue, purple, cyan, reset string
 )
 
 func init() {
@@ -157,10 +190,14 @@ func init() {
 	ALLOWED_COMMANDS["man"] = "man"
 	ALLOWED_COMMANDS["networkmetrics"] = "networkmetrics"
 	ALLOWED_COMMANDS["podmetrics"] = "podmetrics"
+	ALLOWED_COMMANDS["snapshot"] = "snapshot"
+	ALLOWED_COMMANDS["validate-crd"] = "validate-crd"
+	ALLOWED_COMMANDS["kinds"] = "kinds"
+	ALLOWED_COMMANDS["env"] = "env"
+	ALLOWED_COMMANDS["permissions"] = "permissions"
+	ALLOWED_COMMANDS["reachability"] = "reachability"
+	ALLOWED_COMMANDS["storage"] = "storage"
 
-	TotalClusterCompositions = ClusterCompositions{}
-
-	NamespaceToSearch = ""
 
 	DEPLOYMENT = "Deployment"
 	REPLICA_SET = "ReplicaSet"
@@ -178,127 +215,469 @@ func init() {
 	PDB = "PodDisruptionBudget"
 	SERVICE_ACCOUNT = "ServiceAccount"
 	NAMESPACE = "Namespace"
+	NODE = "Node"
+	STORAGE_CLASS = "StorageClass"
+	CLUSTER_ROLE = "ClusterRole"
+	CLUSTER_ROLE_BINDING = "ClusterRoleBinding"
+	CSI_DRIVER = "CSIDriver"
+	ROLE = "Role"
+	ROLE_BINDING = "RoleBinding"
+	NETWORK_POLICY = "NetworkPolicy"
+	HPA = "HorizontalPodAutoscaler"
+	VPA = "VerticalPodAutoscaler"
+	JOB = "Job"
+	CRON_JOB = "CronJob"
+	VALIDATING_WEBHOOK_CONFIGURATION = "ValidatingWebhookConfiguration"
+	MUTATING_WEBHOOK_CONFIGURATION = "MutatingWebhookConfiguration"
+	CRD = "CustomResourceDefinition"
+	API_SERVICE = "APIService"
+	INGRESS_CLASS = "IngressClass"
+	GATEWAY_CLASS = "GatewayClass"
+	GATEWAY = "Gateway"
+	HTTP_ROUTE = "HTTPRoute"
+	GRPC_ROUTE = "GRPCRoute"
+	REFERENCE_GRANT = "ReferenceGrant"
+	VOLUME_SNAPSHOT = "VolumeSnapshot"
+	VOLUME_SNAPSHOT_CONTENT = "VolumeSnapshotContent"
+	VOLUME_SNAPSHOT_CLASS = "VolumeSnapshotClass"
+	VOLUME_ATTACHMENT = "VolumeAttachment"
+	RELATIONSHIP_RULE = "RelationshipRule"
 
 	relTypeLabel = "label"
 	relTypeSpecProperty = "specproperty"
 	relTypeEnvvariable = "envvariable"
 	relTypeAnnotation = "annotation"
 	relTypeOwnerReference = "owner reference"
+	relTypeScheduling = "scheduling"
 
 	green = "\033[32m"
 	red   = "\033[31m"
 	yellow = "\033[33m"
+	blue = "\033[34m"
 	purple = "\033[35m"
 	cyan   = "\033[36m"
 	reset = "\033[0m"
 
-	KindPluralMap = make(map[string]string)
+	KindPluralMap = make(map[schema.GroupKind]string)
 	// TODO: Change this to map[string][]string to support multiple versions
-	kindVersionMap = make(map[string]string) 
-	compositionMap = make(map[string][]string, 0)
-	crdcompositionMap = make(map[string][]string, 0)
-	kindGroupMap = make(map[string]string)
-	relationshipMap = make(map[string][]string)
-
-	kubeObjectListCache = make(map[KubeObjectCacheEntry]interface{})
-	kubeObjectCache = make(map[KubeObjectCacheEntry]interface{})
+	kindVersionMap = make(map[schema.GroupKind]string) 
+	compositionMap = make(map[schema.GroupKind][]string, 0)
+	relationshipMap = make(map[schema.GroupKind][]string)
+	kindShortNames = make(map[schema.GroupKind][]string)
+	scaleReplicasMap = make(map[schema.GroupKind][]string)
+	clusterScopedKinds = make(map[schema.GroupKind]bool)
 
 	// set basic data types
-	KindPluralMap[DEPLOYMENT] = "deployments"
-	kindVersionMap[DEPLOYMENT] = "apis/apps/v1"
-	kindGroupMap[DEPLOYMENT] = "apps"
-	compositionMap[DEPLOYMENT] = []string{"ReplicaSet"}
+	deploymentKind := schema.GroupKind{Group: "apps", Kind: DEPLOYMENT}
+	KindPluralMap[deploymentKind] = "deployments"
+	kindVersionMap[deploymentKind] = "apis/apps/v1"
+	compositionMap[deploymentKind] = []string{"ReplicaSet"}
 	deploymentRelationships := make([]string,0)
 	depRel := "owner reference, of:ReplicaSet, value:INSTANCE.name"
 	deploymentRelationships = append(deploymentRelationships, depRel)
-	relationshipMap[DEPLOYMENT] = deploymentRelationships
+	relationshipMap[deploymentKind] = deploymentRelationships
 
-	KindPluralMap[REPLICA_SET] = "replicasets"
-	kindVersionMap[REPLICA_SET] = "apis/apps/v1"
-	kindGroupMap[REPLICA_SET] = "apps"
-	compositionMap[REPLICA_SET] = []string{"Pod"}
+	replicasetKind := schema.GroupKind{Group: "apps", Kind: REPLICA_SET}
+	KindPluralMap[replicasetKind] = "replicasets"
+	kindVersionMap[replicasetKind] = "apis/apps/v1"
+	compositionMap[replicasetKind] = []string{"Pod"}
 	replicasetRelationships := make([]string,0)
 	replicasetRel := "owner reference, of:Pod, value:INSTANCE.name"
 	replicasetRelationships = append(replicasetRelationships, replicasetRel)
-	relationshipMap[REPLICA_SET] = replicasetRelationships
-
-	KindPluralMap[DAEMONSET] = "daemonsets"
-	kindVersionMap[DAEMONSET] = "apis/apps/v1"
-	kindGroupMap[DAEMONSET] = "apps"
-	compositionMap[DAEMONSET] = []string{"Pod"}
-
-	KindPluralMap[RC] = "replicationcontrollers"
-	kindVersionMap[RC] = "api/v1"
-	kindGroupMap[RC] = ""
-	compositionMap[RC] = []string{"Pod"}
-
-	KindPluralMap[PDB] = "poddisruptionbudgets"
-	kindVersionMap[PDB] = "apis/policy/v1beta1"
-	kindGroupMap[PDB] = "policy"
-	compositionMap[PDB] = []string{}
-
-	KindPluralMap[POD] = "pods"
-	kindVersionMap[POD] = "api/v1"
-	kindGroupMap[POD] = ""
-	compositionMap[POD] = []string{}
+	relationshipMap[replicasetKind] = replicasetRelationships
+
+	daemonsetKind := schema.GroupKind{Group: "apps", Kind: DAEMONSET}
+	KindPluralMap[daemonsetKind] = "daemonsets"
+	kindVersionMap[daemonsetKind] = "apis/apps/v1"
+	compositionMap[daemonsetKind] = []string{"Pod"}
+
+	rcKind := schema.GroupKind{Group: "", Kind: RC}
+	KindPluralMap[rcKind] = "replicationcontrollers"
+	kindVersionMap[rcKind] = "api/v1"
+	compositionMap[rcKind] = []string{"Pod"}
+
+	cronJobKind := schema.GroupKind{Group: "batch", Kind: CRON_JOB}
+	KindPluralMap[cronJobKind] = "cronjobs"
+	kindVersionMap[cronJobKind] = "apis/batch/v1"
+	compositionMap[cronJobKind] = []string{"Job"}
+	cronJobRelationships := make([]string,0)
+	cronJobRel := "owner reference, of:Job, value:INSTANCE.name"
+	cronJobRelationships = append(cronJobRelationships, cronJobRel)
+	relationshipMap[cronJobKind] = cronJobRelationships
+
+	jobKind := schema.GroupKind{Group: "batch", Kind: JOB}
+	KindPluralMap[jobKind] = "jobs"
+	kindVersionMap[jobKind] = "apis/batch/v1"
+	compositionMap[jobKind] = []string{"Pod"}
+	jobRelationships := make([]string,0)
+	jobRel := "owner reference, of:Pod, value:INSTANCE.name"
+	jobRelationships = append(jobRelationships, jobRel)
+	relationshipMap[jobKind] = jobRelationships
+
+	pdbKind := schema.GroupKind{Group: "policy", Kind: PDB}
+	KindPluralMap[pdbKind] = "poddisruptionbudgets"
+	kindVersionMap[pdbKind] = "apis/policy/v1"
+	compositionMap[pdbKind] = []string{}
+	pdbRelationships := make([]string,0)
+	pdbRel := "label, on:Pod, value:INSTANCE.spec.selector"
+	pdbRelationships = append(pdbRelationships, pdbRel)
+	relationshipMap[pdbKind] = pdbRelationships
+
+	// scaleTargetRef and targetRef give the kind they refer to. CRDs with a
+	// scale subresource are added when the CRDs are read.
+	hpaKind := schema.GroupKind{Group: "autoscaling", Kind: HPA}
+	KindPluralMap[hpaKind] = "horizontalpodautoscalers"
+	kindVersionMap[hpaKind] = "apis/autoscaling/v2"
+	compositionMap[hpaKind] = []string{}
+	hpaRelationships := make([]string,0)
+	hpaRel := "specproperty, on:INSTANCE.spec.scaleTargetRef.name, value:Deployment.metadata.name"
+	hpaRel1 := "specproperty, on:INSTANCE.spec.scaleTargetRef.name, value:StatefulSet.metadata.name"
+	hpaRel2 := "specproperty, on:INSTANCE.spec.scaleTargetRef.name, value:ReplicaSet.metadata.name"
+	hpaRel3 := "specproperty, on:INSTANCE.spec.scaleTargetRef.name, value:ReplicationController.metadata.name"
+	hpaRelationships = append(hpaRelationships, hpaRel)
+	hpaRelationships = append(hpaRelationships, hpaRel1)
+	hpaRelationships = append(hpaRelationships, hpaRel2)
+	hpaRelationships = append(hpaRelationships, hpaRel3)
+	relationshipMap[hpaKind] = hpaRelationships
+
+	vpaKind := schema.GroupKind{Group: "autoscaling.k8s.io", Kind: VPA}
+	KindPluralMap[vpaKind] = "verticalpodautoscalers"
+	kindVersionMap[vpaKind] = "apis/autoscaling.k8s.io/v1"
+	compositionMap[vpaKind] = []string{}
+	vpaRelationships := make([]string,0)
+	vpaRel := "specproperty, on:INSTANCE.spec.targetRef.name, value:Deployment.metadata.name"
+	vpaRel1 := "specproperty, on:INSTANCE.spec.targetRef.name, value:StatefulSet.metadata.name"
+	vpaRel2 := "specproperty, on:INSTANCE.spec.targetRef.name, value:DaemonSet.metadata.name"
+	vpaRel3 := "specproperty, on:INSTANCE.spec.targetRef.name, value:ReplicaSet.metadata.name"
+	vpaRel4 := "specproperty, on:INSTANCE.spec.targetRef.name, value:ReplicationController.metadata.name"
+	vpaRelationships = append(vpaRelationships, vpaRel)
+	vpaRelationships = append(vpaRelationships, vpaRel1)
+	vpaRelationships = append(vpaRelationships, vpaRel2)
+	vpaRelationships = append(vpaRelationships, vpaRel3)
+	vpaRelationships = append(vpaRelationships, vpaRel4)
+	relationshipMap[vpaKind] = vpaRelationships
+
+	podKind := schema.GroupKind{Group: "", Kind: POD}
+	KindPluralMap[podKind] = "pods"
+	kindVersionMap[podKind] = "api/v1"
+	compositionMap[podKind] = []string{}
 
 	podRelationships := make([]string,0)
 	podRel0 := "specproperty, on:INSTANCE.spec.env, value:Service.spec.metadata.name"
 	podRel1 := "specproperty, on:INSTANCE.spec.volumes.persistentVolumeClaim.claimName, value:PersistentVolumeClaim.spec.metadata.name"
 	podRel2 := "specproperty, on:INSTANCE.spec.serviceAccountName, value:ServiceAccount.metadata.name"
 	podRel3 := "specproperty, on:INSTANCE.metadata.namespace, value:Namespace.metadata.name"	
+	podRel4 := "specproperty, on:INSTANCE.spec.env, value:ConfigMap.metadata.name"
+	podRel5 := "specproperty, on:INSTANCE.spec.env, value:Secret.metadata.name"
+	// Volumes and image pull secrets
+	podRel6 := "specproperty, on:INSTANCE.spec.volumes.configMap.name, value:ConfigMap.metadata.name"
+	podRel7 := "specproperty, on:INSTANCE.spec.volumes.secret.secretName, value:Secret.metadata.name"
+	podRel8 := "specproperty, on:INSTANCE.spec.volumes.projected.sources.configMap.name, value:ConfigMap.metadata.name"
+	podRel9 := "specproperty, on:INSTANCE.spec.volumes.projected.sources.secret.name, value:Secret.metadata.name"
+	podRel10 := "specproperty, on:INSTANCE.spec.volumes.csi.nodePublishSecretRef.name, value:Secret.metadata.name"
+	podRel11 := "specproperty, on:INSTANCE.spec.volumes.csi.driver, value:CSIDriver.metadata.name"
+	podRel12 := "specproperty, on:INSTANCE.spec.imagePullSecrets.name, value:Secret.metadata.name"
 	podRelationships = append(podRelationships, podRel0)
 	podRelationships = append(podRelationships, podRel1)
 	podRelationships = append(podRelationships, podRel2)
 	podRelationships = append(podRelationships, podRel3)
-	relationshipMap[POD] = podRelationships
-
-	KindPluralMap[SERVICE_ACCOUNT] = "serviceaccounts"
-	kindVersionMap[SERVICE_ACCOUNT] = "api/v1"
-	kindGroupMap[SERVICE_ACCOUNT] = ""
-	compositionMap[SERVICE_ACCOUNT] = []string{}
-
-	KindPluralMap[NAMESPACE] = "namespaces"
-	kindVersionMap[NAMESPACE] = "v1"
-	kindGroupMap[NAMESPACE] = ""
-	compositionMap[NAMESPACE] = []string{}
-
-	KindPluralMap[SERVICE] = "services"
-	kindVersionMap[SERVICE] = "api/v1"
-	kindGroupMap[SERVICE] = ""
-	compositionMap[SERVICE] = []string{}
+	podRelationships = append(podRelationships, podRel4)
+	podRelationships = append(podRelationships, podRel5)
+	podRelationships = append(podRelationships, podRel6)
+	podRelationships = append(podRelationships, podRel7)
+	podRelationships = append(podRelationships, podRel8)
+	podRelationships = append(podRelationships, podRel9)
+	podRelationships = append(podRelationships, podRel10)
+	podRelationships = append(podRelationships, podRel11)
+	podRelationships = append(podRelationships, podRel12)
+	// The Node a Pod runs on and the Nodes it may run on, the Pods it is
+	// placed with or away from
+	podRel13 := "scheduling, on:Node, value:INSTANCE.spec"
+	podRel14 := "scheduling, on:Pod, value:INSTANCE.spec.affinity.podAffinity"
+	podRel15 := "scheduling, on:Pod, value:INSTANCE.spec.affinity.podAntiAffinity"
+	podRel16 := "scheduling, on:Pod, value:INSTANCE.spec.topologySpreadConstraints"
+	podRelationships = append(podRelationships, podRel13)
+	podRelationships = append(podRelationships, podRel14)
+	podRelationships = append(podRelationships, podRel15)
+	podRelationships = append(podRelationships, podRel16)
+	relationshipMap[podKind] = podRelationships
+
+	serviceAccountKind := schema.GroupKind{Group: "", Kind: SERVICE_ACCOUNT}
+	KindPluralMap[serviceAccountKind] = "serviceaccounts"
+	kindVersionMap[serviceAccountKind] = "api/v1"
+	compositionMap[serviceAccountKind] = []string{}
+
+	namespaceKind := schema.GroupKind{Group: "", Kind: NAMESPACE}
+	KindPluralMap[namespaceKind] = "namespaces"
+	kindVersionMap[namespaceKind] = "v1"
+	compositionMap[namespaceKind] = []string{}
+
+	serviceKind := schema.GroupKind{Group: "", Kind: SERVICE}
+	KindPluralMap[serviceKind] = "services"
+	kindVersionMap[serviceKind] = "api/v1"
+	compositionMap[serviceKind] = []string{}
 	serviceRelationships := make([]string,0)
 	serviceRel := "label, on:Pod, value:INSTANCE.spec.selector"
+	serviceRel1 := "specproperty, on:INSTANCE.spec.externalName, value:Service.metadata.name"
 	serviceRelationships = append(serviceRelationships, serviceRel)
-	relationshipMap[SERVICE] = serviceRelationships
+	serviceRelationships = append(serviceRelationships, serviceRel1)
+	relationshipMap[serviceKind] = serviceRelationships
 
-	KindPluralMap[INGRESS] = "ingresses"
-	kindVersionMap[INGRESS] = "networking.k8s.io/v1"//"extensions/v1beta1"
-	kindGroupMap[INGRESS] = "networking.k8s.io"
-	compositionMap[INGRESS] = []string{}
+	ingressKind := schema.GroupKind{Group: "networking.k8s.io", Kind: INGRESS}
+	KindPluralMap[ingressKind] = "ingresses"
+	kindVersionMap[ingressKind] = "networking.k8s.io/v1"//"extensions/v1beta1"
+	compositionMap[ingressKind] = []string{}
 	ingressRelationships := make([]string,0)
 	ingressRel := "specproperty, on:INSTANCE.spec.rules.http.paths.backend.service.name, value:Service.spec.metadata.name"
+	ingressRel1 := "specproperty, on:INSTANCE.spec.defaultBackend.service.name, value:Service.metadata.name"
+	ingressRel2 := "specproperty, on:INSTANCE.spec.tls.secretName, value:Secret.metadata.name"
+	ingressRel3 := "specproperty, on:INSTANCE.spec.ingressClassName, value:IngressClass.metadata.name"
 	ingressRelationships = append(ingressRelationships, ingressRel)
-	relationshipMap[INGRESS] = ingressRelationships
-
-	KindPluralMap[SECRET] = "secrets"
-	kindVersionMap[SECRET] = "v1"
-	kindGroupMap[SECRET] = ""
-	compositionMap[SECRET] = []string{}
-
-	KindPluralMap[PVCLAIM] = "persistentvolumeclaims"
-	kindVersionMap[PVCLAIM] = "api/v1"
-	kindGroupMap[PVCLAIM] = ""
-	compositionMap[PVCLAIM] = []string{}
+	ingressRelationships = append(ingressRelationships, ingressRel1)
+	ingressRelationships = append(ingressRelationships, ingressRel2)
+	ingressRelationships = append(ingressRelationships, ingressRel3)
+	relationshipMap[ingressKind] = ingressRelationships
+
+	// Ingresses that still name their class in the old annotation
+	ingressClassKind := schema.GroupKind{Group: "networking.k8s.io", Kind: INGRESS_CLASS}
+	KindPluralMap[ingressClassKind] = "ingressclasses"
+	kindVersionMap[ingressClassKind] = "apis/networking.k8s.io/v1"
+	ingressClassRelationships := make([]string,0)
+	ingressClassRel := "annotation, on:Ingress, key:kubernetes.io/ingress.class, value:INSTANCE.metadata.name"
+	ingressClassRelationships = append(ingressClassRelationships, ingressClassRel)
+	relationshipMap[ingressClassKind] = ingressClassRelationships
+
+	// Gateway API. References give their kind and, across namespaces, their namespace.
+	gatewayClassKind := schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: GATEWAY_CLASS}
+	KindPluralMap[gatewayClassKind] = "gatewayclasses"
+	kindVersionMap[gatewayClassKind] = "apis/gateway.networking.k8s.io/v1"
+
+	gatewayKind := schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: GATEWAY}
+	KindPluralMap[gatewayKind] = "gateways"
+	kindVersionMap[gatewayKind] = "apis/gateway.networking.k8s.io/v1"
+	gatewayRelationships := make([]string,0)
+	gatewayRel := "specproperty, on:INSTANCE.spec.gatewayClassName, value:GatewayClass.metadata.name"
+	gatewayRel1 := "specproperty, on:INSTANCE.spec.listeners.tls.certificateRefs.name, value:Secret.metadata.name"
+	gatewayRelationships = append(gatewayRelationships, gatewayRel)
+	gatewayRelationships = append(gatewayRelationships, gatewayRel1)
+	relationshipMap[gatewayKind] = gatewayRelationships
+
+	httpRouteKind := schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: HTTP_ROUTE}
+	KindPluralMap[httpRouteKind] = "httproutes"
+	kindVersionMap[httpRouteKind] = "apis/gateway.networking.k8s.io/v1"
+	httpRouteRelationships := make([]string,0)
+	httpRouteRel := "specproperty, on:INSTANCE.spec.parentRefs.name, value:Gateway.metadata.name"
+	httpRouteRel1 := "specproperty, on:INSTANCE.spec.rules.backendRefs.name, value:Service.metadata.name"
+	httpRouteRelationships = append(httpRouteRelationships, httpRouteRel)
+	httpRouteRelationships = append(httpRouteRelationships, httpRouteRel1)
+	relationshipMap[httpRouteKind] = httpRouteRelationships
+
+	grpcRouteKind := schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: GRPC_ROUTE}
+	KindPluralMap[grpcRouteKind] = "grpcroutes"
+	kindVersionMap[grpcRouteKind] = "apis/gateway.networking.k8s.io/v1"
+	grpcRouteRelationships := make([]string,0)
+	grpcRouteRel := "specproperty, on:INSTANCE.spec.parentRefs.name, value:Gateway.metadata.name"
+	grpcRouteRel1 := "specproperty, on:INSTANCE.spec.rules.backendRefs.name, value:Service.metadata.name"
+	grpcRouteRelationships = append(grpcRouteRelationships, grpcRouteRel)
+	grpcRouteRelationships = append(grpcRouteRelationships, grpcRouteRel1)
+	relationshipMap[grpcRouteKind] = grpcRouteRelationships
+
+	// The namespaces allowed to refer to the Services and Secrets of the
+	// ReferenceGrant's namespace
+	referenceGrantKind := schema.GroupKind{Group: "gateway.networking.k8s.io", Kind: REFERENCE_GRANT}
+	KindPluralMap[referenceGrantKind] = "referencegrants"
+	kindVersionMap[referenceGrantKind] = "apis/gateway.networking.k8s.io/v1beta1"
+	referenceGrantRelationships := make([]string,0)
+	referenceGrantRel := "specproperty, on:INSTANCE.spec.from.namespace, value:Namespace.metadata.name"
+	referenceGrantRel1 := "specproperty, on:INSTANCE.spec.to.name, value:Service.metadata.name"
+	referenceGrantRel2 := "specproperty, on:INSTANCE.spec.to.name, value:Secret.metadata.name"
+	referenceGrantRelationships = append(referenceGrantRelationships, referenceGrantRel)
+	referenceGrantRelationships = append(referenceGrantRelationships, referenceGrantRel1)
+	referenceGrantRelationships = append(referenceGrantRelationships, referenceGrantRel2)
+	relationshipMap[referenceGrantKind] = referenceGrantRelationships
+
+	// The Pods a NetworkPolicy applies to, and the Pods and Namespaces its
+	// rules allow traffic from or to
+	networkPolicyKind := schema.GroupKind{Group: "networking.k8s.io", Kind: NETWORK_POLICY}
+	KindPluralMap[networkPolicyKind] = "networkpolicies"
+	kindVersionMap[networkPolicyKind] = "apis/networking.k8s.io/v1"
+	compositionMap[networkPolicyKind] = []string{}
+	networkPolicyRelationships := make([]string,0)
+	netpolRel := "label, on:Pod, value:INSTANCE.spec.podSelector"
+	netpolRel1 := "label, on:Pod, value:INSTANCE.spec.ingress.from.podSelector[*]"
+	netpolRel2 := "label, on:Pod, value:INSTANCE.spec.egress.to.podSelector[*]"
+	netpolRel3 := "label, on:Namespace, value:INSTANCE.spec.ingress.from.namespaceSelector[*]"
+	netpolRel4 := "label, on:Namespace, value:INSTANCE.spec.egress.to.namespaceSelector[*]"
+	networkPolicyRelationships = append(networkPolicyRelationships, netpolRel)
+	networkPolicyRelationships = append(networkPolicyRelationships, netpolRel1)
+	networkPolicyRelationships = append(networkPolicyRelationships, netpolRel2)
+	networkPolicyRelationships = append(networkPolicyRelationships, netpolRel3)
+	networkPolicyRelationships = append(networkPolicyRelationships, netpolRel4)
+	relationshipMap[networkPolicyKind] = networkPolicyRelationships
+
+	secretKind := schema.GroupKind{Group: "", Kind: SECRET}
+	KindPluralMap[secretKind] = "secrets"
+	kindVersionMap[secretKind] = "v1"
+	compositionMap[secretKind] = []string{}
+
+	pvcKind := schema.GroupKind{Group: "", Kind: PVCLAIM}
+	KindPluralMap[pvcKind] = "persistentvolumeclaims"
+	kindVersionMap[pvcKind] = "api/v1"
+	compositionMap[pvcKind] = []string{}
 	pvcRelationships := make([]string,0)
 	pvcRel := "specproperty, on:INSTANCE.spec.volumeName, value:PersistentVolume.metadata.name"
+	pvcRel1 := "specproperty, on:INSTANCE.spec.storageClassName, value:StorageClass.metadata.name"
+	// Restored from a snapshot or cloned from another claim
+	pvcRel2 := "specproperty, on:INSTANCE.spec.dataSource.name, value:VolumeSnapshot.metadata.name"
+	pvcRel3 := "specproperty, on:INSTANCE.spec.dataSource.name, value:PersistentVolumeClaim.metadata.name"
 	pvcRelationships = append(pvcRelationships, pvcRel)
-	relationshipMap[PVCLAIM] = pvcRelationships
-
-	KindPluralMap[PV] = "persistentvolumes"
-	kindVersionMap[PV] = "api/v1"
-	kindGroupMap[PV] = ""
-	compositionMap[PV] = []string{}
+	pvcRelationships = append(pvcRelationships, pvcRel1)
+	pvcRelationships = append(pvcRelationships, pvcRel2)
+	pvcRelationships = append(pvcRelationships, pvcRel3)
+	relationshipMap[pvcKind] = pvcRelationships
+
+	pvKind := schema.GroupKind{Group: "", Kind: PV}
+	KindPluralMap[pvKind] = "persistentvolumes"
+	kindVersionMap[pvKind] = "api/v1"
+	compositionMap[pvKind] = []string{}
+	pvRelationships := make([]string,0)
+	pvRel := "specproperty, on:INSTANCE.spec.storageClassName, value:StorageClass.metadata.name"
+	pvRel1 := "specproperty, on:INSTANCE.spec.csi.driver, value:CSIDriver.metadata.name"
+	pvRelationships = append(pvRelationships, pvRel)
+	pvRelationships = append(pvRelationships, pvRel1)
+	relationshipMap[pvKind] = pvRelationships
+
+	nodeKind := schema.GroupKind{Group: "", Kind: NODE}
+	KindPluralMap[nodeKind] = "nodes"
+	kindVersionMap[nodeKind] = "api/v1"
+
+	storageClassKind := schema.GroupKind{Group: "storage.k8s.io", Kind: STORAGE_CLASS}
+	KindPluralMap[storageClassKind] = "storageclasses"
+	kindVersionMap[storageClassKind] = "apis/storage.k8s.io/v1"
+	storageClassRelationships := make([]string,0)
+	storageClassRel := "specproperty, on:INSTANCE.provisioner, value:CSIDriver.metadata.name"
+	storageClassRelationships = append(storageClassRelationships, storageClassRel)
+	relationshipMap[storageClassKind] = storageClassRelationships
+
+	csiDriverKind := schema.GroupKind{Group: "storage.k8s.io", Kind: CSI_DRIVER}
+	KindPluralMap[csiDriverKind] = "csidrivers"
+	kindVersionMap[csiDriverKind] = "apis/storage.k8s.io/v1"
+
+	volumeAttachmentKind := schema.GroupKind{Group: "storage.k8s.io", Kind: VOLUME_ATTACHMENT}
+	KindPluralMap[volumeAttachmentKind] = "volumeattachments"
+	kindVersionMap[volumeAttachmentKind] = "apis/storage.k8s.io/v1"
+	volumeAttachmentRelationships := make([]string,0)
+	volumeAttachmentRel := "specproperty, on:INSTANCE.spec.source.persistentVolumeName, value:PersistentVolume.metadata.name"
+	volumeAttachmentRel1 := "specproperty, on:INSTANCE.spec.nodeName, value:Node.metadata.name"
+	volumeAttachmentRel2 := "specproperty, on:INSTANCE.spec.attacher, value:CSIDriver.metadata.name"
+	volumeAttachmentRelationships = append(volumeAttachmentRelationships, volumeAttachmentRel)
+	volumeAttachmentRelationships = append(volumeAttachmentRelationships, volumeAttachmentRel1)
+	volumeAttachmentRelationships = append(volumeAttachmentRelationships, volumeAttachmentRel2)
+	relationshipMap[volumeAttachmentKind] = volumeAttachmentRelationships
+
+	// CSI snapshots. The VolumeSnapshotContent gives the namespace of its
+	// VolumeSnapshot.
+	volumeSnapshotKind := schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT}
+	KindPluralMap[volumeSnapshotKind] = "volumesnapshots"
+	kindVersionMap[volumeSnapshotKind] = "apis/snapshot.storage.k8s.io/v1"
+	volumeSnapshotRelationships := make([]string,0)
+	volumeSnapshotRel := "specproperty, on:INSTANCE.spec.source.persistentVolumeClaimName, value:PersistentVolumeClaim.metadata.name"
+	volumeSnapshotRel1 := "specproperty, on:INSTANCE.spec.volumeSnapshotClassName, value:VolumeSnapshotClass.metadata.name"
+	volumeSnapshotRel2 := "specproperty, on:INSTANCE.status.boundVolumeSnapshotContentName, value:VolumeSnapshotContent.metadata.name"
+	volumeSnapshotRelationships = append(volumeSnapshotRelationships, volumeSnapshotRel)
+	volumeSnapshotRelationships = append(volumeSnapshotRelationships, volumeSnapshotRel1)
+	volumeSnapshotRelationships = append(volumeSnapshotRelationships, volumeSnapshotRel2)
+	relationshipMap[volumeSnapshotKind] = volumeSnapshotRelationships
+
+	volumeSnapshotContentKind := schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT_CONTENT}
+	KindPluralMap[volumeSnapshotContentKind] = "volumesnapshotcontents"
+	kindVersionMap[volumeSnapshotContentKind] = "apis/snapshot.storage.k8s.io/v1"
+	volumeSnapshotContentRelationships := make([]string,0)
+	vscRel := "specproperty, on:INSTANCE.spec.volumeSnapshotRef.name, value:VolumeSnapshot.metadata.name"
+	vscRel1 := "specproperty, on:INSTANCE.spec.volumeSnapshotClassName, value:VolumeSnapshotClass.metadata.name"
+	vscRel2 := "specproperty, on:INSTANCE.spec.driver, value:CSIDriver.metadata.name"
+	volumeSnapshotContentRelationships = append(volumeSnapshotContentRelationships, vscRel)
+	volumeSnapshotContentRelationships = append(volumeSnapshotContentRelationships, vscRel1)
+	volumeSnapshotContentRelationships = append(volumeSnapshotContentRelationships, vscRel2)
+	relationshipMap[volumeSnapshotContentKind] = volumeSnapshotContentRelationships
+
+	volumeSnapshotClassKind := schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: VOLUME_SNAPSHOT_CLASS}
+	KindPluralMap[volumeSnapshotClassKind] = "volumesnapshotclasses"
+	kindVersionMap[volumeSnapshotClassKind] = "apis/snapshot.storage.k8s.io/v1"
+	volumeSnapshotClassRelationships := make([]string,0)
+	volumeSnapshotClassRel := "specproperty, on:INSTANCE.driver, value:CSIDriver.metadata.name"
+	volumeSnapshotClassRelationships = append(volumeSnapshotClassRelationships, volumeSnapshotClassRel)
+	relationshipMap[volumeSnapshotClassKind] = volumeSnapshotClassRelationships
+
+	clusterRoleKind := schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: CLUSTER_ROLE}
+	KindPluralMap[clusterRoleKind] = "clusterroles"
+	kindVersionMap[clusterRoleKind] = "apis/rbac.authorization.k8s.io/v1"
+	clusterRoleRelationships := make([]string,0)
+	// Aggregated ClusterRoles
+	clusterRoleRel := "label, on:ClusterRole, value:INSTANCE.aggregationRule.clusterRoleSelectors[*]"
+	clusterRoleRelationships = append(clusterRoleRelationships, clusterRoleRel)
+	relationshipMap[clusterRoleKind] = clusterRoleRelationships
+
+	clusterRoleBindingKind := schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: CLUSTER_ROLE_BINDING}
+	KindPluralMap[clusterRoleBindingKind] = "clusterrolebindings"
+	kindVersionMap[clusterRoleBindingKind] = "apis/rbac.authorization.k8s.io/v1"
+	clusterRoleBindingRelationships := make([]string,0)
+	crbRel := "specproperty, on:INSTANCE.roleRef.name, value:ClusterRole.metadata.name"
+	crbRel1 := "specproperty, on:INSTANCE.subjects.name, value:ServiceAccount.metadata.name"
+	clusterRoleBindingRelationships = append(clusterRoleBindingRelationships, crbRel)
+	clusterRoleBindingRelationships = append(clusterRoleBindingRelationships, crbRel1)
+	relationshipMap[clusterRoleBindingKind] = clusterRoleBindingRelationships
+
+	roleKind := schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: ROLE}
+	KindPluralMap[roleKind] = "roles"
+	kindVersionMap[roleKind] = "apis/rbac.authorization.k8s.io/v1"
+
+	// roleRef and subjects give the kind they refer to
+	roleBindingKind := schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: ROLE_BINDING}
+	KindPluralMap[roleBindingKind] = "rolebindings"
+	kindVersionMap[roleBindingKind] = "apis/rbac.authorization.k8s.io/v1"
+	roleBindingRelationships := make([]string,0)
+	rbRel := "specproperty, on:INSTANCE.roleRef.name, value:Role.metadata.name"
+	rbRel1 := "specproperty, on:INSTANCE.roleRef.name, value:ClusterRole.metadata.name"
+	rbRel2 := "specproperty, on:INSTANCE.subjects.name, value:ServiceAccount.metadata.name"
+	roleBindingRelationships = append(roleBindingRelationships, rbRel)
+	roleBindingRelationships = append(roleBindingRelationships, rbRel1)
+	roleBindingRelationships = append(roleBindingRelationships, rbRel2)
+	relationshipMap[roleBindingKind] = roleBindingRelationships
+
+	// Services backing admission webhooks, conversion webhooks and
+	// aggregated APIs. The service gives its namespace.
+	validatingWebhookKind := schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: VALIDATING_WEBHOOK_CONFIGURATION}
+	KindPluralMap[validatingWebhookKind] = "validatingwebhookconfigurations"
+	kindVersionMap[validatingWebhookKind] = "apis/admissionregistration.k8s.io/v1"
+	validatingWebhookRelationships := make([]string,0)
+	validatingWebhookRel := "specproperty, on:INSTANCE.webhooks.clientConfig.service.name, value:Service.metadata.name"
+	validatingWebhookRelationships = append(validatingWebhookRelationships, validatingWebhookRel)
+	relationshipMap[validatingWebhookKind] = validatingWebhookRelationships
+
+	mutatingWebhookKind := schema.GroupKind{Group: "admissionregistration.k8s.io", Kind: MUTATING_WEBHOOK_CONFIGURATION}
+	KindPluralMap[mutatingWebhookKind] = "mutatingwebhookconfigurations"
+	kindVersionMap[mutatingWebhookKind] = "apis/admissionregistration.k8s.io/v1"
+	mutatingWebhookRelationships := make([]string,0)
+	mutatingWebhookRel := "specproperty, on:INSTANCE.webhooks.clientConfig.service.name, value:Service.metadata.name"
+	mutatingWebhookRelationships = append(mutatingWebhookRelationships, mutatingWebhookRel)
+	relationshipMap[mutatingWebhookKind] = mutatingWebhookRelationships
+
+	crdKind := schema.GroupKind{Group: "apiextensions.k8s.io", Kind: CRD}
+	KindPluralMap[crdKind] = "customresourcedefinitions"
+	kindVersionMap[crdKind] = "apis/apiextensions.k8s.io/v1"
+	crdRelationships := make([]string,0)
+	crdRel := "specproperty, on:INSTANCE.spec.conversion.webhook.clientConfig.service.name, value:Service.metadata.name"
+	crdRelationships = append(crdRelationships, crdRel)
+	relationshipMap[crdKind] = crdRelationships
+
+	apiServiceKind := schema.GroupKind{Group: "apiregistration.k8s.io", Kind: API_SERVICE}
+	KindPluralMap[apiServiceKind] = "apiservices"
+	kindVersionMap[apiServiceKind] = "apis/apiregistration.k8s.io/v1"
+	apiServiceRelationships := make([]string,0)
+	apiServiceRel := "specproperty, on:INSTANCE.spec.service.name, value:Service.metadata.name"
+	apiServiceRelationships = append(apiServiceRelationships, apiServiceRel)
+	relationshipMap[apiServiceKind] = apiServiceRelationships
 
 /*
 	KindPluralMap[INGRESS] = "ingresses"
@@ -307,21 +686,80 @@ func init() {
 	compositionMap[INGRESS] = []string{}
 */
 
-	KindPluralMap[STATEFULSET] = "statefulsets"
-	kindVersionMap[STATEFULSET] = "apis/apps/v1"
-	kindGroupMap[STATEFULSET] = "apps"
-	compositionMap[STATEFULSET] = []string{"Pod", "ReplicaSet"}
+	statefulsetKind := schema.GroupKind{Group: "apps", Kind: STATEFULSET}
+	KindPluralMap[statefulsetKind] = "statefulsets"
+	kindVersionMap[statefulsetKind] = "apis/apps/v1"
+	compositionMap[statefulsetKind] = []string{"Pod", "ReplicaSet"}
 	ssetRelationships := make([]string,0)
 	ssRel1 := "owner reference, of:ReplicaSet, value:INSTANCE.name"
 	ssetRelationships = append(ssetRelationships, ssRel1)
 	ssRel2 := "owner reference, of:Pod, value:INSTANCE.name"
 	ssetRelationships = append(ssetRelationships, ssRel2)
-	relationshipMap[STATEFULSET] = ssetRelationships
-
-	KindPluralMap[CONFIG_MAP] = "configmaps"
-	kindVersionMap[CONFIG_MAP] = "api/v1"
-	kindGroupMap[CONFIG_MAP] = ""
-	compositionMap[CONFIG_MAP] = []string{}
+	// Claims made from volumeClaimTemplates carry the labels of the selector
+	ssRel3 := "label, on:PersistentVolumeClaim, value:INSTANCE.spec.selector"
+	ssetRelationships = append(ssetRelationships, ssRel3)
+	relationshipMap[statefulsetKind] = ssetRelationships
+
+	configMapKind := schema.GroupKind{Group: "", Kind: CONFIG_MAP}
+	KindPluralMap[configMapKind] = "configmaps"
+	kindVersionMap[configMapKind] = "api/v1"
+	compositionMap[configMapKind] = []string{}
+
+	// Compositions and relationships of other kinds, see rules.go
+	relationshipRuleKind := schema.GroupKind{Group: "kubediscovery.cloudark.io", Kind: RELATIONSHIP_RULE}
+	KindPluralMap[relationshipRuleKind] = "relationshiprules"
+	kindVersionMap[relationshipRuleKind] = "apis/kubediscovery.cloudark.io/v1"
+
+	kindShortNames[deploymentKind] = []string{"deploy"}
+	kindShortNames[replicasetKind] = []string{"rs"}
+	kindShortNames[daemonsetKind] = []string{"ds"}
+	kindShortNames[rcKind] = []string{"rc"}
+	kindShortNames[cronJobKind] = []string{"cj"}
+	kindShortNames[pdbKind] = []string{"pdb"}
+	kindShortNames[podKind] = []string{"po"}
+	kindShortNames[serviceAccountKind] = []string{"sa"}
+	kindShortNames[namespaceKind] = []string{"ns"}
+	kindShortNames[serviceKind] = []string{"svc"}
+	kindShortNames[ingressKind] = []string{"ing"}
+	kindShortNames[pvcKind] = []string{"pvc"}
+	kindShortNames[pvKind] = []string{"pv"}
+	kindShortNames[statefulsetKind] = []string{"sts"}
+	kindShortNames[configMapKind] = []string{"cm"}
+	kindShortNames[nodeKind] = []string{"no"}
+	kindShortNames[storageClassKind] = []string{"sc"}
+	kindShortNames[networkPolicyKind] = []string{"netpol"}
+	kindShortNames[hpaKind] = []string{"hpa"}
+	kindShortNames[vpaKind] = []string{"vpa"}
+	kindShortNames[crdKind] = []string{"crd", "crds"}
+	kindShortNames[gatewayClassKind] = []string{"gc"}
+	kindShortNames[gatewayKind] = []string{"gtw"}
+	kindShortNames[referenceGrantKind] = []string{"refgrant"}
+	kindShortNames[volumeSnapshotKind] = []string{"vs"}
+	kindShortNames[volumeSnapshotContentKind] = []string{"vsc", "vscs"}
+	kindShortNames[volumeSnapshotClassKind] = []string{"vsclass", "vsclasses"}
+
+	scaleReplicasMap[deploymentKind] = []string{".spec.replicas", ".status.replicas"}
+	scaleReplicasMap[replicasetKind] = []string{".spec.replicas", ".status.replicas"}
+	scaleReplicasMap[statefulsetKind] = []string{".spec.replicas", ".status.replicas"}
+	scaleReplicasMap[rcKind] = []string{".spec.replicas", ".status.replicas"}
+
+	clusterScopedKinds[namespaceKind] = true
+	clusterScopedKinds[pvKind] = true
+	clusterScopedKinds[nodeKind] = true
+	clusterScopedKinds[storageClassKind] = true
+	clusterScopedKinds[clusterRoleKind] = true
+	clusterScopedKinds[csiDriverKind] = true
+	clusterScopedKinds[clusterRoleBindingKind] = true
+	clusterScopedKinds[validatingWebhookKind] = true
+	clusterScopedKinds[mutatingWebhookKind] = true
+	clusterScopedKinds[crdKind] = true
+	clusterScopedKinds[apiServiceKind] = true
+	clusterScopedKinds[ingressClassKind] = true
+	clusterScopedKinds[gatewayClassKind] = true
+	clusterScopedKinds[volumeAttachmentKind] = true
+	clusterScopedKinds[volumeSnapshotContentKind] = true
+	clusterScopedKinds[volumeSnapshotClassKind] = true
+	clusterScopedKinds[relationshipRuleKind] = true
 
 	USAGE_ANNOTATION = "resource/usage"
 	COMPOSITION_ANNOTATION = "resource/composition"
@@ -330,13 +768,252 @@ func init() {
 	SPECPROPERTY_REL_ANNOTATION = "resource/specproperty-relationship"
 }
 
-func getKindAPIDetails(kind string) (string, string, string, string) {
-	kindplural := KindPluralMap[kind]
-	kindResourceApiVersion := kindVersionMap[kind]
-	kindResourceGroup := kindGroupMap[kind]
+// Kinds known to a single query: the built-in kinds plus the kinds
+// added from CRDs or the KIND_COMPOSITION_FILE. All maps are keyed by
+// GroupKind so that CRDs with the same Kind in different groups coexist.
+//
+// Within a query a kind is named by its Kind, or by Kind.group when another
+// group defines the same Kind (see kindName and resolveKind).
+type kindRegistry struct {
+	// Resolves kinds to the resources served by the API server. Nil when
+	// reading manifests, in which case only the maps below are used.
+	mapper   meta.RESTMapper
+	mappings map[schema.GroupKind]*meta.RESTMapping
+	// Set once the mapper has been reset by this query
+	mapperReset bool
+	// Names already resolved by resolveKind
+	names map[string]schema.GroupKind
+
+	pluralMap         map[schema.GroupKind]string
+	versionMap        map[schema.GroupKind]string
+	compositionMap    map[schema.GroupKind][]string
+	relationshipMap   map[schema.GroupKind][]string
+	crdcompositionMap map[schema.GroupKind][]string
+	shortNames        map[schema.GroupKind][]string
+	scaleReplicasMap  map[schema.GroupKind][]string
+	clusterScopedMap  map[schema.GroupKind]bool
+	loaded            bool
+
+	// Where compositions and relationships that are not built in were
+	// declared, keyed by component kind or declaration (see Kinds)
+	compositionSources  map[schema.GroupKind]map[string]string
+	relationshipSources map[schema.GroupKind]map[string]string
+	// Declarations of RelationshipRules that do not parse, with the error
+	ruleErrors map[schema.GroupKind]map[string]string
+}
+
+func newKindRegistry(mapper meta.RESTMapper) *kindRegistry {
+	return &kindRegistry{
+		mapper:            mapper,
+		mappings:          make(map[schema.GroupKind]*meta.RESTMapping),
+		names:             make(map[string]schema.GroupKind),
+		pluralMap:         copyKindMap(KindPluralMap),
+		versionMap:        copyKindMap(kindVersionMap),
+		compositionMap:    copyKindSliceMap(compositionMap),
+		relationshipMap:   copyKindSliceMap(relationshipMap),
+		crdcompositionMap: make(map[schema.GroupKind][]string, 0),
+		shortNames:        copyKindSliceMap(kindShortNames),
+		scaleReplicasMap:  copyKindSliceMap(scaleReplicasMap),
+		clusterScopedMap:  make(map[schema.GroupKind]bool),
+
+		compositionSources:  make(map[schema.GroupKind]map[string]string),
+		relationshipSources: make(map[schema.GroupKind]map[string]string),
+		ruleErrors:          make(map[schema.GroupKind]map[string]string),
+	}
+}
+
+func copyKindMap(in map[schema.GroupKind]string) map[schema.GroupKind]string {
+	out := make(map[schema.GroupKind]string, len(in))
+	for key, value := range in {
+		out[key] = value
+	}
+	return out
+}
+
+func copyKindSliceMap(in map[schema.GroupKind][]string) map[schema.GroupKind][]string {
+	out := make(map[schema.GroupKind][]string, len(in))
+	for key, value := range in {
+		out[key] = append([]string{}, value...)
+	}
+	return out
+}
+
+// Group of an endpoint such as "apis/apps/v1", "api/v1" or "networking.k8s.io/v1".
+func endpointGroup(endpoint string) string {
+	endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "apis/"), "api/")
+	gv, err := schema.ParseGroupVersion(endpoint)
+	if err != nil {
+		return ""
+	}
+	return gv.Group
+}
+
+func resourceEndpoint(res schema.GroupVersionResource) string {
+	if res.Group == "" {
+		return "api/" + res.Version
+	}
+	return "apis/" + res.Group + "/" + res.Version
+}
+
+func (r *kindRegistry) getKindAPIDetails(kind string) (string, string, string, string) {
+	gk := r.groupKind(kind)
+	if mapping := r.restMapping(gk); mapping != nil {
+		res := mapping.Resource
+		return res.Resource, resourceEndpoint(res), res.Version, res.Group
+	}
+
+	kindplural := r.pluralMap[gk]
+	kindResourceApiVersion := r.versionMap[gk]
 
 	parts := strings.Split(kindResourceApiVersion, "/")
 	kindAPI := parts[len(parts)-1]
 
-	return kindplural, kindResourceApiVersion, kindAPI, kindResourceGroup
+	return kindplural, kindResourceApiVersion, kindAPI, gk.Group
+}
+
+// Preferred resource of gk as served by the API server, or nil if there is
+// no mapper or the kind is not served. Results are cached for the query.
+func (r *kindRegistry) restMapping(gk schema.GroupKind) *meta.RESTMapping {
+	if r.mapper == nil || gk.Kind == "" {
+		return nil
+	}
+	if mapping, ok := r.mappings[gk]; ok {
+		return mapping
+	}
+	mapping, _ := r.mapper.RESTMapping(gk)
+	// The CRD may have been created after API discovery was cached
+	_, fromCRD := r.crdcompositionMap[gk]
+	if resettable, ok := r.mapper.(interface{ Reset() }); mapping == nil && fromCRD && ok && !r.mapperReset {
+		r.mapperReset = true
+		resettable.Reset()
+		mapping, _ = r.mapper.RESTMapping(gk)
+	}
+	r.mappings[gk] = mapping
+	return mapping
+}
+
+// Reports whether objects of kind are cluster-scoped. The API server knows
+// the scope of every served kind; otherwise the built-in kinds and the scope
+// of CRDs are used.
+func (r *kindRegistry) clusterScoped(kind string) bool {
+	gk := r.groupKind(kind)
+	if mapping := r.restMapping(gk); mapping != nil {
+		return mapping.Scope.Name() == meta.RESTScopeNameRoot
+	}
+	return clusterScopedKinds[gk] || r.clusterScopedMap[gk]
+}
+
+// Name of gk within a query: the Kind, qualified with the group only when
+// another known group has a kind of the same name.
+func (r *kindRegistry) kindName(gk schema.GroupKind) string {
+	for other := range r.pluralMap {
+		if other.Kind == gk.Kind && other.Group != gk.Group {
+			return gk.String()
+		}
+	}
+	return gk.Kind
+}
+
+// Like resolveKind, but kinds that cannot be resolved are returned with an
+// empty group so that lookups simply find nothing.
+func (r *kindRegistry) groupKind(kind string) schema.GroupKind {
+	gk, err := r.resolveKind(kind)
+	if err != nil {
+		return schema.GroupKind{Kind: kind}
+	}
+	return gk
+}
+
+// Resolves a kind as given on the command line or in a relationship: the
+// Kind ("Deployment"), its plural or singular resource name ("deployments"),
+// a short name ("deploy"), any of these qualified with the group
+// ("Deployment.apps", "postgreses.db.example.com"), or a name that API
+// discovery knows.
+func (r *kindRegistry) resolveKind(name string) (schema.GroupKind, error) {
+	name = strings.TrimSpace(name)
+	if gk, ok := r.names[name]; ok {
+		return gk, nil
+	}
+	matches := r.matchKind(name)
+	if len(matches) == 0 && r.mapper != nil && name != "" {
+		gvk, err := r.mapper.KindFor(schema.ParseGroupResource(name).WithVersion(""))
+		if err == nil {
+			r.addServedKind(gvk)
+			matches = append(matches, gvk.GroupKind())
+		} else if meta.IsAmbiguousError(err) {
+			return schema.GroupKind{}, newError(ErrUnknownKind, "Kind %s is ambiguous: %s", name, err.Error())
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return schema.GroupKind{}, newError(ErrUnknownKind, "Unknown kind %s", name)
+	case 1:
+		r.names[name] = matches[0]
+		return matches[0], nil
+	}
+	qualified := make([]string, 0)
+	for _, gk := range matches {
+		qualified = append(qualified, gk.String())
+	}
+	sort.Strings(qualified)
+	return schema.GroupKind{}, newError(ErrUnknownKind, "Kind %s is ambiguous, use one of %s", name, strings.Join(qualified, ", "))
+}
+
+// Resolves the target kind of a relationship declared by from. A Kind
+// defined in more than one group refers to the one in the group of from.
+func (r *kindRegistry) relatedKind(name string, from schema.GroupKind) string {
+	matches := r.matchKind(strings.TrimSpace(name))
+	if len(matches) > 1 {
+		for _, gk := range matches {
+			if gk.Group == from.Group {
+				return r.kindName(gk)
+			}
+		}
+	}
+	gk, err := r.resolveKind(name)
+	if err != nil {
+		return strings.TrimSpace(name)
+	}
+	return r.kindName(gk)
+}
+
+// Known kinds that name refers to. An exact Kind or Kind.group wins over
+// plurals, lower case names and short names.
+func (r *kindRegistry) matchKind(name string) []schema.GroupKind {
+	exact := make([]schema.GroupKind, 0)
+	matches := make([]schema.GroupKind, 0)
+	lower := strings.ToLower(name)
+	for gk, plural := range r.pluralMap {
+		if name == gk.Kind || name == gk.String() {
+			exact = append(exact, gk)
+			continue
+		}
+		aliases := append([]string{strings.ToLower(gk.Kind), plural}, r.shortNames[gk]...)
+		for _, alias := range aliases {
+			if lower == alias || (gk.Group != "" && lower == alias+"."+strings.ToLower(gk.Group)) {
+				matches = append(matches, gk)
+				break
+			}
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return matches
+}
+
+// Adds a kind found through API discovery that is neither built in nor
+// read from a CRD, so that it can be named like any other kind.
+func (r *kindRegistry) addServedKind(gvk schema.GroupVersionKind) {
+	gk := gvk.GroupKind()
+	if _, ok := r.pluralMap[gk]; ok {
+		return
+	}
+	mapping, err := r.mapper.RESTMapping(gk, gvk.Version)
+	if err != nil {
+		return
+	}
+	r.mappings[gk] = mapping
+	r.pluralMap[gk] = mapping.Resource.Resource
+	r.versionMap[gk] = resourceEndpoint(mapping.Resource)
 }
@@ -4,14 +4,14 @@ import (
 	"os"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"sort"
+	"strings"
 	"sync"
-	"path/filepath"
 	"github.com/coreos/etcd/client"
-	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -20,95 +20,40 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
-var (
-	cfg *rest.Config
-	err error
-	dynamicClient dynamic.Interface
-    wg sync.WaitGroup
-    mu sync.RWMutex
-)
-
-func BuildConfig1() (*rest.Config, error) {
-	if home := homeDir(); home != "" {
-		kubeconfig := filepath.Join(home, ".kube", "config")
-		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			//fmt.Printf("kubeconfig error:%s\n", err.Error())
-			//fmt.Printf("Trying inClusterConfig..")
-			cfg, err = rest.InClusterConfig()
-			if err != nil {
-				panic(err)
-				//return nil, err
-			}
-		}
-	}
-	return cfg, nil
-}
-
-func homeDir() string {
-	if h := os.Getenv("HOME"); h != "" {
-		return h
-	}
-	return os.Getenv("USERPROFILE") // windows
-}
-
 func BuildConfig(kubeconfigpath string) (*rest.Config, error) {
+	var cfg *rest.Config
+	var err error
 	if kubeconfigpath == "" {
 		cfg, err = rest.InClusterConfig()
-                if err != nil {
-                     panic(err)
-                                //return nil, err
-                 }
-		return cfg, err
-
-	}
-
-	if yyy, err := os.Stat(kubeconfigpath); err != nil {
-		fmt.Printf("YYY:%v\n",yyy)
-		fmt.Printf("Kubeconfigpath:%s\n", kubeconfigpath)
-		fmt.Printf("Error:%v\n",err)
-		panic(err)
-	}
-
-	if _, err := os.Stat(kubeconfigpath); err == nil {
-		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfigpath)
 		if err != nil {
-			fmt.Printf("kubeconfig error:%s\n", err.Error())
-		}
-	} else if home := homeDir(); home != "" {
-		kubeconfig := filepath.Join(home, ".kube", "config")
-		cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			//fmt.Printf("kubeconfig error:%s\n", err.Error())
-			//fmt.Printf("Trying inClusterConfig..")
-			cfg, err = rest.InClusterConfig()
-			if err != nil {
-				panic(err)
-				//return nil, err
-			}
+			return nil, err
 		}
+		return cfg, nil
 	}
-	return cfg, nil
-}
 
-func getDynamicClient() (dynamic.Interface, error) {
-	if cfg == nil {
-		_, _ = BuildConfig1()
+	if _, err := os.Stat(kubeconfigpath); err != nil {
+		return nil, fmt.Errorf("kubeconfig %s: %s", kubeconfigpath, err.Error())
 	}
-	if dynamicClient == nil {
-		dynamicClient, err = dynamic.NewForConfig(cfg)
+
+	cfg, err = clientcmd.BuildConfigFromFlags("", kubeconfigpath)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig %s: %s", kubeconfigpath, err.Error())
 	}
-	return dynamicClient, err
+	return cfg, nil
 }
 
-func FetchGVKs(namespace string) {
+func (q *query) fetchGVKs(namespace string) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
 	kindPrefetchList := [...]string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Service", "ServiceAccount", "Pod", "PersistentVolume", "PersistentVolumeClaim","Secret"}
 	for _, k := range kindPrefetchList {
 		wg.Add(1)
 		fmt.Printf("Kind:%s\n", k)
 		go func(kind string) {
 			defer wg.Done()
-		childResKindPlural, _, childResApiVersion, childResGroup := getKindAPIDetails(k)
+			mu.Lock()
+			defer mu.Unlock()
+		childResKindPlural, _, childResApiVersion, childResGroup := q.registry.getKindAPIDetails(kind)
 		childRes := schema.GroupVersionResource{Group: childResGroup,
 										 		Version: childResApiVersion,
 										   		Resource: childResKindPlural}
@@ -117,46 +62,186 @@ func FetchGVKs(namespace string) {
 
 
 			fmt.Printf("Fetching %s\n", kind)
-			mu.Lock()
-			getKubeObjectList(k, namespace, childRes)
-			mu.Unlock()
+			q.getKubeObjectList(kind, namespace, childRes)
 		}(k)
 	}
 	wg.Wait()
 }
 
-func getKubeObjectList(kind, namespace string, gvk schema.GroupVersionResource) (*unstructured.UnstructuredList, error) {
+// Namespace to read objects of kind from: none for cluster-scoped kinds.
+func (q *query) kindNamespace(kind, namespace string) string {
+	if q.registry.clusterScoped(kind) {
+		return ""
+	}
+	return namespace
+}
+
+// Namespaces of the query, starting with namespace. Just "" when all
+// namespaces are searched.
+func (q *query) queryNamespaces(namespace string) []string {
+	namespaces := []string{namespace}
+	for _, ns := range q.d.options.Namespaces {
+		if ns == metav1.NamespaceAll {
+			return []string{metav1.NamespaceAll}
+		}
+		if ns != namespace {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// Namespaces that objects related to an object of kind in namespace can be
+// in. Namespaced objects only refer to objects in their own namespace;
+// cluster-scoped objects can be related to objects in any namespace of the
+// query. The first namespace is always namespace.
+func (q *query) relatedNamespaces(kind, namespace string) []string {
+	if kind == NAMESPACE || !q.registry.clusterScoped(kind) {
+		return []string{namespace}
+	}
+	namespaces := q.queryNamespaces(namespace)
+	if namespaces[0] != namespace {
+		namespaces = append([]string{namespace}, namespaces...)
+	}
+	return namespaces
+}
+
+// Namespace of a related object. Objects read from manifests may not have
+// one, in which case they are taken to be in namespace.
+func (q *query) objectNamespace(kind string, obj *unstructured.Unstructured, namespace string) string {
+	if q.registry.clusterScoped(kind) {
+		return ""
+	}
+	if obj.GetNamespace() != "" {
+		return obj.GetNamespace()
+	}
+	return namespace
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sameNamespace(lhs, rhs *unstructured.Unstructured) bool {
+	return lhs.GetNamespace() == "" || rhs.GetNamespace() == "" || lhs.GetNamespace() == rhs.GetNamespace()
+}
+
+// Whether rhsObj of kind is in the namespace ref, found on lhsObj, refers
+// to. That is the namespace given in the reference, or else the namespace
+// of lhsObj.
+func (q *query) namespaceMatches(ref fieldReference, lhsObj *unstructured.Unstructured, kind string, rhsObj *unstructured.Unstructured) bool {
+	if q.registry.clusterScoped(kind) {
+		return true
+	}
+	if ref.Namespace != "" {
+		return rhsObj.GetNamespace() == "" || rhsObj.GetNamespace() == ref.Namespace
+	}
+	return sameNamespace(lhsObj, rhsObj)
+}
+
+// References that give the kind of their target, like the roleRef of a
+// RoleBinding, only refer to objects of that kind. When they give an
+// apiVersion too, like a scaleTargetRef, the group has to match as well.
+func (q *query) kindMatches(ref fieldReference, kind string) bool {
+	if ref.Kind == "" {
+		return true
+	}
+	gk := q.registry.groupKind(kind)
+	if ref.APIVersion != "" {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err == nil && gv.Group != gk.Group {
+			return false
+		}
+	}
+	return ref.Kind == gk.Kind
+}
+
+// Adds the namespaces given in refs to namespaces. The first namespace
+// stays first.
+func addReferencedNamespaces(namespaces []string, refs []fieldReference) []string {
+	for _, ref := range refs {
+		if ref.Namespace != "" && !containsString(namespaces, ref.Namespace) {
+			namespaces = append(namespaces, ref.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// Reference to a Service by its DNS name: name, name.namespace,
+// name.namespace.svc or name.namespace.svc.cluster.local. Names like
+// example.com or v1.2 are only read as name.namespace when the namespace is
+// known, or has the Service, as in manifests without Namespace objects.
+func (q *query) serviceReference(dnsName string) fieldReference {
+	parts := strings.Split(dnsName, ".")
+	if (len(parts) > 2 && parts[2] == "svc") || (len(parts) == 2 && q.knownNamespace(parts[1])) ||
+		(len(parts) == 2 && q.serviceExists(parts[0], parts[1])) {
+		return fieldReference{Value: parts[0], Namespace: parts[1]}
+	}
+	return fieldReference{Value: dnsName}
+}
+
+// Namespaces of the query, and those that exist
+func (q *query) knownNamespace(namespace string) bool {
+	if containsString(q.d.options.Namespaces, namespace) {
+		return true
+	}
+	_, err := q.getKubeObject(NAMESPACE, namespace, "", q.kindResource(NAMESPACE))
+	return err == nil
+}
+
+func (q *query) serviceExists(name, namespace string) bool {
+	_, err := q.getKubeObject(SERVICE, name, namespace, q.kindResource(SERVICE))
+	return err == nil
+}
+
+// Env variables can name a Service in another namespace by its DNS name
+func (q *query) envReference(targetKind, envValue string) fieldReference {
+	if q.registry.groupKind(targetKind) == (schema.GroupKind{Kind: SERVICE}) {
+		return q.serviceReference(envValue)
+	}
+	return fieldReference{Value: envValue}
+}
+
+func (q *query) getKubeObjectList(kind, namespace string, gvk schema.GroupVersionResource) (*unstructured.UnstructuredList, error) {
+	namespace = q.kindNamespace(kind, namespace)
 	found := false
 	var objectList *unstructured.UnstructuredList
-	for k, v := range kubeObjectListCache {
+	var err error
+	for k, v := range q.listCache {
 		if k.Kind == kind && k.Namespace == namespace && checkGVK(k.GVK, gvk) {
 			found = true
 			//fmt.Printf("Kind:%s found in cache\n", kind)
-			objectList = v.(*unstructured.UnstructuredList)
+			objectList = v
 		}
 	}
 	if !found {
+		if gvk.Resource == "" {
+			return nil, newError(ErrUnknownKind, "Unknown kind %s", kind)
+		}
 		//fmt.Printf("Kind:%s not found in cache\n", kind)
-		objectList, err = dynamicClient.Resource(gvk).Namespace(namespace).List(context.TODO(),
-																		   		 	metav1.ListOptions{})
-		if err != nil { // Check if this is a non-namespaced resource
-			objectList, err = dynamicClient.Resource(gvk).List(context.TODO(), metav1.ListOptions{})
-			if err != nil {
-				//panic(err)
-				return nil, err
-			}
+		objectList, err = q.client.Resource(gvk).Namespace(namespace).List(q.ctx,
+																		   metav1.ListOptions{})
+		if err != nil {
+			//panic(err)
+			return nil, fromAPIError(err)
 		}
 		entry := KubeObjectCacheEntry{
 			Namespace: namespace,
 			Kind: kind,
 			GVK: gvk, 
 		}
-		kubeObjectListCache[entry] = objectList
+		q.listCache[entry] = objectList
 	}
 	return objectList, nil
 }
 
-func getKubeObject(kind, instance, namespace string, gvk schema.GroupVersionResource) (unstructured.Unstructured, error) {
+func (q *query) getKubeObject(kind, instance, namespace string, gvk schema.GroupVersionResource) (unstructured.Unstructured, error) {
+	namespace = q.kindNamespace(kind, namespace)
 	found := false
 	var obj unstructured.Unstructured
 
@@ -166,10 +251,10 @@ func getKubeObject(kind, instance, namespace string, gvk schema.GroupVersionReso
 		GVK: gvk, 
 	}
 
-	objList, ok := kubeObjectListCache[entry]
+	objList, ok := q.listCache[entry]
 	if ok {
-		for _, k := range objList.(*unstructured.UnstructuredList).Items {
-			if k.GetKind() == kind && k.GetNamespace() == namespace && k.GetName() == instance {
+		for _, k := range objList.Items {
+			if k.GetNamespace() == namespace && k.GetName() == instance {
 				found = true
 				//fmt.Printf("Kind:%s found in cache\n", kind)
 				obj = k
@@ -177,17 +262,16 @@ func getKubeObject(kind, instance, namespace string, gvk schema.GroupVersionReso
 		}
 	}
 	if !found {
+		if gvk.Resource == "" {
+			return obj, newError(ErrUnknownKind, "Unknown kind %s", kind)
+		}
 		//fmt.Printf("Kind:%s not found in cache\n", kind)
-		obj1, err := dynamicClient.Resource(gvk).Namespace(namespace).Get(context.TODO(),
-																			 instance,
-																	   		 metav1.GetOptions{})
-
-		if err != nil { // Check if this is a non-namespaced resource
-			obj1, err = dynamicClient.Resource(gvk).Get(context.TODO(), instance, metav1.GetOptions{})
-			if err != nil {
-				//panic(err)
-				return obj, err
-			}
+		obj1, err := q.client.Resource(gvk).Namespace(namespace).Get(q.ctx,
+																	 instance,
+																	 metav1.GetOptions{})
+		if err != nil {
+			//panic(err)
+			return obj, fromAPIError(err)
 		}
 		entry := KubeObjectCacheEntry{
 			Namespace: namespace,
@@ -195,7 +279,7 @@ func getKubeObject(kind, instance, namespace string, gvk schema.GroupVersionReso
 			Name: instance,
 			GVK: gvk, 
 		}
-		kubeObjectCache[entry] = obj1
+		q.objectCache[entry] = obj1
 		obj = *obj1
 	}
 	return obj, nil
@@ -233,7 +317,7 @@ func getComposition1(kind, name, status string, compositionTree *[]CompositionTr
 			childName := metaDataNode.MetaDataName
 			childStatus := metaDataNode.Status
 			fmt.Printf("  %d %s %s\n", level, childKind, childName)
-			compositionString = compositionString + " " + string(level) + " " + childKind + " " + childName + "\n"
+			compositionString = compositionString + " " + strconv.Itoa(level) + " " + childKind + " " + childName + "\n"
 			childComposition.Level = level
 			childComposition.Kind = childKind
 			childComposition.Name = childName
@@ -320,52 +404,50 @@ func (cp *ClusterCompositions) PrintCompositions() {
 	}
 }
 
-func printMaps() {
+func (r *kindRegistry) printMaps() {
 	fmt.Println("Printing kindVersionMap")
-	for key, value := range kindVersionMap {
+	for key, value := range r.versionMap {
 		fmt.Printf("%s, %s\n", key, value)
 	}
 	fmt.Println("Printing KindPluralMap")
-	for key, value := range KindPluralMap {
+	for key, value := range r.pluralMap {
 		fmt.Printf("%s, %s\n", key, value)
 	}
 	fmt.Println("Printing compositionMap")
-	for key, value := range compositionMap {
+	for key, value := range r.compositionMap {
 		fmt.Printf("%s, %s\n", key, value)
 	}
 }
 
 // Connection utility functions
-func CheckExistence(kind, instance, namespace string) bool {
-	if instance == "" {
-		return false
-	}
-	dynamicClient, err := getDynamicClient()
-	if err != nil {
-		//fmt.Printf("Error: %s\n", err.Error())
-		return false
+func (q *query) checkExistence(kind, instance, namespace string) error {
+	resourceKindPlural, _, resourceApiVersion, resourceGroup := q.registry.getKindAPIDetails(kind)
+	if resourceKindPlural == "" {
+		return newError(ErrUnknownKind, "Unknown kind %s", kind)
 	}
-	resourceKindPlural, _, resourceApiVersion, resourceGroup := getKindAPIDetails(kind)
 	res := schema.GroupVersionResource{Group: resourceGroup,
 									   Version: resourceApiVersion,
 									   Resource: resourceKindPlural}
-	_, err = dynamicClient.Resource(res).Namespace(namespace).Get(context.TODO(),
-																			 instance,
-																	   		 metav1.GetOptions{})
-	if err != nil {
-		_, err1 := dynamicClient.Resource(res).Get(context.TODO(),instance,metav1.GetOptions{})
-		if err1 != nil {
-			return false
-		}
+	var err error
+	if instance == "" {
+		err = ErrNotFound
+	} else {
+		_, err = q.getKubeObject(kind, instance, namespace, res)
+	}
+	if errors.Is(err, ErrNotFound) && q.registry.clusterScoped(kind) {
+		return newError(ErrNotFound, "Resource %s of kind %s does not exist.", instance, kind)
+	}
+	if errors.Is(err, ErrNotFound) {
+		return newError(ErrNotFound, "Resource %s of kind %s in namespace %s does not exist.", instance, kind, namespace)
 	}
-	return true
+	return err
 }
 
-func findRelatedKinds1(kind string) []string{
+func (q *query) findRelatedKinds1(kind string) []string{
 	relatedKinds := make([]string, 0)
-	relStringList := relationshipMap[kind]
+	relStringList := q.registry.relationshipMap[q.registry.groupKind(kind)]
 	for _, relString := range relStringList {
-		_, _, _, targetKindList := parseRelationship(relString)
+		_, _, _, targetKindList, _ := q.registry.parseRelationship(kind, relString)
 		for _, targetKind := range targetKindList {
 			//fmt.Printf("Kind:%s TargetKind:%s\n", kind, targetKind)
 			relatedKinds = append(relatedKinds, targetKind)
@@ -377,15 +459,18 @@ func findRelatedKinds1(kind string) []string{
 }
 
 
-func findRelatedKinds(kind string) []string{
+func (q *query) findRelatedKinds(kind string) []string{
 	relatedKinds := make([]string, 0)
-	for key, relStringList := range relationshipMap {
+	for key, relStringList := range q.registry.relationshipMap {
+		relatedKind := q.registry.kindName(key)
 		for _, relString := range relStringList {
-			_, _, _, targetKindList := parseRelationship(relString)
+			// Invalid relationships are reported when they are followed
+			_, _, _, targetKindList, _ := q.registry.parseRelationship(relatedKind, relString)
 			for _, targetKind := range targetKindList {
 				//fmt.Printf("Kind:%s TargetKind:%s\n", kind, targetKind)
-				if targetKind == kind {
-					relatedKinds = append(relatedKinds, key)
+				// Each relationship of relatedKind is followed once it is listed
+				if targetKind == kind && !containsString(relatedKinds, relatedKind) {
+					relatedKinds = append(relatedKinds, relatedKind)
 				}
 			}
 		}
@@ -394,11 +479,11 @@ func findRelatedKinds(kind string) []string{
 	return relatedKinds
 }
 
-func findChildKinds(kind string) []string {
+func (q *query) findChildKinds(kind string) []string {
 	childKinds := make([]string, 0)
-	for _, relStringList := range relationshipMap {
+	for key, relStringList := range q.registry.relationshipMap {
 		for _, relString := range relStringList {
-			relType, _, _, targetKindList := parseRelationship(relString)
+			relType, _, _, targetKindList, _ := q.registry.parseRelationship(q.registry.kindName(key), relString)
 			if relType == relTypeOwnerReference {
 				for _, tk := range targetKindList {
 					childKinds = append(childKinds, tk)
@@ -409,30 +494,36 @@ func findChildKinds(kind string) []string {
 	return childKinds
 }
 
-func GetCAdvisorMetrics(nodeName string) string {
+func (d *Discoverer) GetCAdvisorMetrics(ctx context.Context, nodeName string) (string, error) {
 	// source: https://github.com/kubernetes/client-go/issues/716
-	clientset, err := kubernetes.NewForConfig(cfg)
+	clientset, err := kubernetes.NewForConfig(d.config)
+	if err != nil {
+		return "", err
+	}
 	request := clientset.CoreV1().RESTClient().Get().Resource("nodes").Name(nodeName).SubResource("proxy").Suffix("metrics/cadvisor")
-	responseRawArrayOfBytes, err := request.DoRaw(context.TODO())
+	responseRawArrayOfBytes, err := request.DoRaw(ctx)
 	if err != nil {
-		return err.Error()
+		return "", fromAPIError(err)
 	}
 	responseToReturn := string(responseRawArrayOfBytes)
 	fmt.Printf(responseToReturn)
-	return responseToReturn
+	return responseToReturn, nil
 }
 
-func GetKubeletMetrics(nodeName string) string {
+func (d *Discoverer) GetKubeletMetrics(ctx context.Context, nodeName string) (string, error) {
 	// source: https://github.com/kubernetes/client-go/issues/716
-	clientset, err := kubernetes.NewForConfig(cfg)
+	clientset, err := kubernetes.NewForConfig(d.config)
+	if err != nil {
+		return "", err
+	}
 	request := clientset.CoreV1().RESTClient().Get().Resource("nodes").Name(nodeName).SubResource("proxy").Suffix("stats/summary")
-	responseRawArrayOfBytes, err := request.DoRaw(context.TODO())
+	responseRawArrayOfBytes, err := request.DoRaw(ctx)
 	if err != nil {
-		return err.Error()
+		return "", fromAPIError(err)
 	}
 	responseToReturn := string(responseRawArrayOfBytes)
 	//fmt.Printf(responseToReturn)
-	return responseToReturn
+	return responseToReturn, nil
 }
 
 func deepCopy(input Connection) Connection {
@@ -447,6 +538,8 @@ func deepCopy(input Connection) Connection {
 	output.RelationDetails = input.RelationDetails
 	output.OwnerKind = input.OwnerKind
 	output.OwnerName = input.OwnerName
+	output.Attributes = input.Attributes
+	output.Inferred = input.Inferred
 	return output
 }
 
@@ -477,6 +570,8 @@ func printConnectionsJSON(connections []Connection) {
 			PeerNamespace: conn.Peer.Namespace,
 			RelationType: conn.RelationType,
 			RelationDetails: conn.RelationDetails,
+			Attributes: conn.Attributes,
+			Inferred: conn.Inferred,
 		}
 		connectionsOutput = append(connectionsOutput, op)
 	}
@@ -520,9 +615,9 @@ func printNode(connection Connection, printtype, relType string) {
 
 	var relativeEntry string
 	if printtype == "flat" {
-		relativeEntry = "Level:" + levelStr + " kind:" + connection.Kind + " name:" + connection.Name + relType
+		relativeEntry = "Level:" + levelStr + " kind:" + connection.Kind + " name:" + connection.Name + attributesString(connection.Attributes) + relType
 	} else {
-		relativeEntry = "Level:" + levelStr + " " + connection.Kind + "/" + connection.Name + relType
+		relativeEntry = "Level:" + levelStr + " " + connection.Kind + "/" + connection.Name + attributesString(connection.Attributes) + relType
 	}
 	fmt.Printf(relativeEntry + "\n")
 }
@@ -547,6 +642,11 @@ func printPath(connections []Connection, printtype string) {
 					relType = relType + yellow + connection.RelationType + reset
 				case relTypeOwnerReference:
 					relType = relType + cyan + connection.RelationType + reset
+				case relTypeScheduling:
+					relType = relType + blue + connection.RelationType + reset
+				}
+				if connection.Inferred {
+					relType = relType + " (inferred)"
 				}
 				relationType = " [related to " + connection.Peer.Kind + "/" + connection.Peer.Name +  " by:" + relType + "]"
 			} else {
@@ -565,7 +665,11 @@ func printConnectionsTabs(connections []Connection) {
 		for t:=1; t<level; t++ {
 			fmt.Printf("\t")
 		}
-		fmt.Printf("%s/%s (related by: %s)\n", connection.Kind, connection.Name, connection.RelationType)
+		relType := connection.RelationType
+		if connection.Inferred {
+			relType = relType + ", inferred"
+		}
+		fmt.Printf("%s/%s%s (related by: %s)\n", connection.Kind, connection.Name, attributesString(connection.Attributes), relType)
 		//fmt.Printf("%s/%s (%s)\n", connection.Kind, connection.Name, connection.Owner)
 	}
 }
@@ -720,11 +824,11 @@ func appendNextLevelPeers(connections, nextLevelConnections []Connection) ([]Con
 	return connections
 }
 
-func prepare(level int, kind, instance string, connections, relativeNames []Connection, targetKind, namespace, relType, relDetail string) ([]Connection) {
+func (q *query) prepare(level int, kind, instance string, connections, relativeNames []Connection, targetKind, namespace, relType, relDetail string) ([]Connection) {
 	preparedConnections := make([]Connection,0)
 	for _, relative := range relativeNames {
 		relativeName := relative.Name
-		ownerKind, ownerInstance := getOwnerDetail(targetKind, relativeName, namespace)
+		ownerKind, ownerInstance, _ := q.getOwnerDetail(targetKind, relativeName, namespace)
 		ownerDetail := "Owner:" + ownerKind + "/" + ownerInstance
 		connection := Connection{
 			Level: level,
@@ -0,0 +1,151 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// CRDReport is the result of checking the kubediscovery annotations of a CRD.
+type CRDReport struct {
+	// Name of the CRD
+	Name string
+	// Kind it defines, as Kind.group
+	Kind string
+	// The kubediscovery annotations the CRD declares
+	Annotations []string
+	Problems []AnnotationProblem
+}
+
+// AnnotationProblem is a problem found in the value of an annotation.
+type AnnotationProblem struct {
+	Annotation string
+	Value string
+	// Byte offset in Value, -1 when the problem is with the value as a whole
+	Pos int
+	Message string
+}
+
+// ValidateCRDs checks the resource/composition, resource/usage and
+// resource/*-relationship annotations of the CRD with the given name or
+// kind, or of all CRDs when name is empty.
+func (d *Discoverer) ValidateCRDs(ctx context.Context, name string) ([]CRDReport, error) {
+	crdList, err := d.listCRDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Relationships and compositions may name kinds of any of the CRDs
+	registry := newKindRegistry(d.mapper)
+	for _, crdObj := range crdList {
+		registry.parseCRDAnnotions(crdObj)
+	}
+	// name can be the name of the CRD or its kind
+	var gk schema.GroupKind
+	var kindErr error
+	if name != "" {
+		gk, kindErr = registry.resolveKind(name)
+	}
+
+	reports := make([]CRDReport, 0)
+	for _, crdObj := range crdList {
+		crdKind := schema.GroupKind{Group: crdObj.Spec.Group, Kind: crdObj.Spec.Names.Kind}
+		if name != "" && crdObj.Name != name && crdKind != gk {
+			continue
+		}
+		report := CRDReport{Name: crdObj.Name, Kind: crdKind.String(), Annotations: []string{}, Problems: []AnnotationProblem{}}
+		annotations := crdObj.GetAnnotations()
+		keys := make([]string, 0)
+		for key, _ := range annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := annotations[key]
+			problem := func(pos int, message string) {
+				report.Problems = append(report.Problems, AnnotationProblem{Annotation: key, Value: value, Pos: pos, Message: message})
+			}
+			switch {
+			case key == COMPOSITION_ANNOTATION:
+				report.Annotations = append(report.Annotations, key)
+				pos := 0
+				for _, kind := range strings.Split(value, ",") {
+					kindPos := skipSpaces(value, pos)
+					if strings.TrimSpace(kind) == "" {
+						problem(kindPos, "missing kind")
+					} else if _, err := registry.resolveKind(strings.TrimSpace(kind)); err != nil {
+						problem(kindPos, err.Error())
+					}
+					pos = pos + len(kind) + 1
+				}
+			case key == USAGE_ANNOTATION:
+				report.Annotations = append(report.Annotations, key)
+				// Name of the ConfigMap holding the usage details
+				for _, msg := range validation.IsDNS1123Subdomain(strings.TrimSpace(value)) {
+					problem(-1, "not a ConfigMap name: "+msg)
+				}
+			case strings.HasPrefix(key, LABEL_REL_ANNOTATION), strings.HasPrefix(key, SPECPROPERTY_REL_ANNOTATION),
+				strings.HasPrefix(key, ANNOTATION_REL_ANNOTATION):
+				report.Annotations = append(report.Annotations, key)
+				relType, ok := relationshipAnnotationType(key)
+				if !ok {
+					base := relationshipAnnotationBase(key)
+					problem(-1, "not read by kubediscovery, use "+base+" or "+base+"<n>")
+					continue
+				}
+				rel, err := parseRelationshipAnnotation(relType, value)
+				if err != nil {
+					var relErr *RelationshipError
+					if errors.As(err, &relErr) {
+						problem(relErr.Pos, relErr.Reason)
+					} else {
+						problem(-1, err.Error())
+					}
+					continue
+				}
+				for _, targetKind := range rel.TargetKinds {
+					if _, err := registry.resolveKind(registry.relatedKind(targetKind, crdKind)); err != nil {
+						problem(strings.Index(value, targetKind), err.Error())
+					}
+				}
+			}
+		}
+		reports = append(reports, report)
+	}
+	if name != "" && len(reports) == 0 {
+		if kindErr != nil && strings.Contains(kindErr.Error(), "ambiguous") {
+			return nil, kindErr
+		}
+		return nil, newError(ErrNotFound, "CustomResourceDefinition %s not found", name)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Name < reports[j].Name
+	})
+	return reports, nil
+}
+
+// The resource/<type>-relationship annotation key starts with
+func relationshipAnnotationBase(key string) string {
+	for _, base := range []string{LABEL_REL_ANNOTATION, SPECPROPERTY_REL_ANNOTATION, ANNOTATION_REL_ANNOTATION} {
+		if strings.HasPrefix(key, base) {
+			return base
+		}
+	}
+	return key
+}
+
+// Relationship type of a resource/<type>-relationship annotation key
+func relationshipAnnotationType(key string) (string, bool) {
+	switch {
+	case isRelationshipKey(key, LABEL_REL_ANNOTATION):
+		return relTypeLabel, true
+	case isRelationshipKey(key, SPECPROPERTY_REL_ANNOTATION):
+		return relTypeSpecProperty, true
+	case isRelationshipKey(key, ANNOTATION_REL_ANNOTATION):
+		return relTypeAnnotation, true
+	}
+	return "", false
+}